@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ueisele/go-docker-utils/pkg/template"
+)
+
+var (
+	convertCmd = &cobra.Command{
+		Use:   "convert [files...]",
+		Short: "Converts yaml, json, toml and properties files into another of these formats.",
+		Long: "Converts yaml, json, toml and properties files into another of these formats. If no file is provided, it is read from stdin. " +
+			"With --merge, multiple files are merged in order, like values files of the template command, and written as one. " +
+			"Without, every file is written into the --out directory with the extension of the output format. " +
+			"Nested maps are flattened into dotted keys, if written as properties. Toml and properties require a map at the top level.",
+		SilenceUsage: true,
+		RunE:         runConvertCmd,
+	}
+	convertFrom      string
+	convertTo        string
+	convertOut       string
+	convertMerge     bool
+	convertSeparator string
+)
+
+func init() {
+	convertCmd.Flags().StringVar(&convertFrom, "from", "", "Input format, one of [yaml, json, toml, properties]. If not provided, it is determined by the extension, and stdin is read as yaml, which includes json.")
+	convertCmd.Flags().StringVar(&convertTo, "to", "", "Output format, one of [yaml, json, toml, properties]. If not provided, it is determined by the extension of --out.")
+	convertCmd.Flags().StringVarP(&convertOut, "out", "o", "", "The output file, or the output directory for multiple files without --merge. If not provided, it is written to stdout.")
+	convertCmd.Flags().BoolVarP(&convertMerge, "merge", "m", false, "Merges multiple files into one.")
+	convertCmd.Flags().StringVar(&convertSeparator, "separator", ".", "Separator of keys, if nested maps are flattened for properties.")
+}
+
+func runConvertCmd(cmd *cobra.Command, args []string) error {
+	to := convertTo
+	if to != "" {
+		if err := template.CheckFormat(to); err != nil {
+			return usageError(err)
+		}
+	} else {
+		if convertOut == "" || isDir(convertOut) {
+			return usageError(fmt.Errorf("either --to or --out with a known extension is required"))
+		}
+		var err error
+		if to, err = template.FormatOf(convertOut); err != nil {
+			return usageError(err)
+		}
+	}
+	inputs, err := loadStructuredFiles(args, convertFrom, cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+
+	if len(inputs) == 1 || convertMerge {
+		data, err := mergeStructuredFiles(inputs)
+		if err != nil {
+			return err
+		}
+		text, err := encodeConverted(to, data)
+		if err != nil {
+			return err
+		}
+		if convertOut == "" || convertOut == "-" {
+			_, err = fmt.Fprint(cmd.OutOrStdout(), text)
+			return err
+		}
+		return writeFileKeepMode(convertOut, []byte(text))
+	}
+
+	if convertOut == "" || !isDir(convertOut) {
+		return usageError(fmt.Errorf("multiple files require --merge or an existing directory as --out"))
+	}
+	for _, input := range inputs {
+		text, err := encodeConverted(to, input.data)
+		if err != nil {
+			return fmt.Errorf("could not convert %s: %v", input.name, err)
+		}
+		base := strings.TrimSuffix(filepath.Base(input.name), filepath.Ext(input.name))
+		if err := writeFileKeepMode(filepath.Join(convertOut, base+"."+to), []byte(text)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeConverted(format string, data interface{}) (string, error) {
+	if format == template.FormatTOML || format == template.FormatProperties {
+		if reflect.ValueOf(data).Kind() != reflect.Map {
+			return "", fmt.Errorf("%s requires a map at the top level, but was %T", format, data)
+		}
+	}
+	if format == template.FormatProperties {
+		data = template.Flatten(data, convertSeparator)
+	}
+	text, err := template.Encode(format, data)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return text, nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
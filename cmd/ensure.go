@@ -9,47 +9,69 @@ import (
 
 var (
 	ensureCmd = &cobra.Command{
-		Use:   "ensure",
-		Short: "Ensures that environment variables are defined.",
-		Long:  "Ensures that environment variables are defined.",
+		Use:          "ensure",
+		Short:        "Ensures that environment variables are defined.",
+		Long:         "Ensures that environment variables are defined.",
 		SilenceUsage: true,
-		RunE:  runEnsureCmd,
+		RunE:         runEnsureCmd,
 	}
 	atLeastOne bool
 )
 
 func init() {
 	ensureCmd.Flags().BoolVarP(&atLeastOne, "at-least-one", "a", false, "By the default it is ensured that all environment variables are defined. If this flag is set, it is enough if at least one is defined.")
+	addOutputFlag(ensureCmd)
 }
 
 func runEnsureCmd(cmd *cobra.Command, args []string) error {
-	var err error
+	reporter, err := newReporter(cmd)
+	if err != nil {
+		return err
+	}
+	var results []*checkResult
 	if atLeastOne {
-		err = checkAtLeastOnePresent(cmd.Flags().Args())
+		results, err = checkAtLeastOnePresent(cmd.Flags().Args())
 	} else {
-		err = checkAllPresent(cmd.Flags().Args())
+		results, err = checkAllPresent(cmd.Flags().Args())
 	}
-	return err
+	return reporter.finish(results, err)
 }
 
-func checkAllPresent(envs []string) error {
+func checkAllPresent(envs []string) ([]*checkResult, error) {
+	results := checkPresent(envs)
 	missingEnvs := make([]string, 0)
-	for _, env := range envs {
-		if len(os.Getenv(env)) == 0 {
-			missingEnvs = append(missingEnvs, env)
+	for _, result := range results {
+		if result.Status != statusPassed {
+			missingEnvs = append(missingEnvs, result.Item)
 		}
 	}
 	if len(missingEnvs) > 0 {
-		return fmt.Errorf("environment variables are missing: %v", missingEnvs)
+		return results, checkFailedError(fmt.Errorf("environment variables are missing: %v", missingEnvs))
 	}
-	return nil
+	return results, nil
 }
 
-func checkAtLeastOnePresent(envs []string) error {
+func checkAtLeastOnePresent(envs []string) ([]*checkResult, error) {
+	results := checkPresent(envs)
+	for _, result := range results {
+		if result.Status == statusPassed {
+			return results, nil
+		}
+	}
+	return results, checkFailedError(fmt.Errorf("none of the specified environment variables is present: %v", envs))
+}
+
+func checkPresent(envs []string) []*checkResult {
+	results := make([]*checkResult, 0, len(envs))
 	for _, env := range envs {
-		if len(os.Getenv(env)) > 0 {
-			return nil
+		result := startCheck(env)
+		result.Attempts = 1
+		if len(os.Getenv(env)) == 0 {
+			result.complete(checkFailedError(fmt.Errorf("not defined")))
+		} else {
+			result.complete(nil)
 		}
+		results = append(results, result)
 	}
-	return fmt.Errorf("none of the specified environment variables is present: %v", envs)
-}
\ No newline at end of file
+	return results
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/magiconair/properties"
+	"github.com/spf13/cobra"
+
+	"github.com/ueisele/go-docker-utils/pkg/template"
+)
+
+var (
+	envnamesCmd = &cobra.Command{
+		Use:   "envnames [properties...]",
+		Short: "Converts property names to environment variable names.",
+		Long: "Converts property names to environment variable names. This is the inverse of the envToProp and envToConfig template functions. " +
+			"Property names are taken from the arguments and from the keys of properties files.",
+		SilenceUsage: true,
+		RunE:         runEnvnamesCmd,
+	}
+	envnamesEnvPrefix  string
+	envnamesPropPrefix string
+	envnamesInput      []string
+	envnamesCheck      bool
+	envnamesKeyStyle   string
+)
+
+func init() {
+	envnamesCmd.Flags().StringVarP(&envnamesEnvPrefix, "env-prefix", "e", "", "Prefix of the environment variables (e.g. KAFKA_).")
+	envnamesCmd.Flags().StringVarP(&envnamesPropPrefix, "prop-prefix", "p", "", "Prefix of the properties, which is replaced by the prefix of the environment variables.")
+	envnamesCmd.Flags().StringSliceVarP(&envnamesInput, "in", "i", []string{}, "Properties files (glob pattern), whose keys are converted.")
+	envnamesCmd.Flags().BoolVarP(&envnamesCheck, "check", "c", false, "Fails if a property cannot be represented unambiguously as environment variable.")
+	envnamesCmd.Flags().StringVarP(&envnamesKeyStyle, "key-style", "k", "confluent", "Style of the keys, either one of [camelCase, confluent, kebab, spring-relaxed] or a specification like '_=.,__=-,case=lower'. Must be the same style as in envToConfig of the templates.")
+	addOutputFlag(envnamesCmd)
+}
+
+func runEnvnamesCmd(cmd *cobra.Command, args []string) error {
+	reporter, err := newReporter(cmd)
+	if err != nil {
+		return err
+	}
+	keyStyle, err := template.ParseKeyStyle(envnamesKeyStyle)
+	if err != nil {
+		return reporter.finish(nil, usageError(err))
+	}
+	names := append([]string{}, args...)
+	if len(envnamesInput) > 0 {
+		filenames, err := template.FileGlobsToFileNames(envnamesInput...)
+		if err != nil {
+			return reporter.finish(nil, usageError(fmt.Errorf("could not parse input glob: %v", err)))
+		}
+		for _, filename := range filenames {
+			props, err := properties.LoadFile(filename, properties.UTF8)
+			if err != nil {
+				return reporter.finish(nil, checkFailedError(fmt.Errorf("could not load %s: %v", filename, err)))
+			}
+			names = append(names, props.Keys()...)
+		}
+	}
+	if len(names) == 0 {
+		return reporter.finish(nil, usageError(fmt.Errorf("requires at least one property as argument or properties file")))
+	}
+	results := make([]*checkResult, 0, len(names))
+	ambiguous := make([]string, 0)
+	for _, name := range names {
+		result := startCheck(name)
+		result.Attempts = 1
+		env, err := keyStyle.PropertyToEnv(envnamesPropPrefix, envnamesEnvPrefix, name)
+		if err != nil && env != "" && !envnamesCheck {
+			err = nil
+		}
+		if err != nil {
+			ambiguous = append(ambiguous, fmt.Sprintf("%s -> %v", name, err))
+			result.complete(checkFailedError(err))
+		} else {
+			result.complete(nil)
+			if reporter.format == outputText {
+				fmt.Fprintln(cmd.OutOrStdout(), env)
+			}
+		}
+		result.Value = env
+		results = append(results, result)
+	}
+	if len(ambiguous) > 0 {
+		return reporter.finish(results, checkFailedError(fmt.Errorf("%d properties cannot be converted:\n\t%s", len(ambiguous), strings.Join(ambiguous, "\n\t"))))
+	}
+	return reporter.finish(results, nil)
+}
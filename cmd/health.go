@@ -0,0 +1,373 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	healthCmd = &cobra.Command{
+		Use:   "health",
+		Short: "Runs health checks, suitable as Docker HEALTHCHECK.",
+		Long: "Runs health checks, suitable as Docker HEALTHCHECK. Supported are TCP connects, HTTP status codes, file freshness and process existence. " +
+			"Checks can be defined by flags or by a config file. The command completes with exit status 1 if any check fails.",
+		SilenceUsage: true,
+		RunE:         runHealthCmd,
+	}
+	healthConfigFile string
+	healthTimeout    time.Duration
+	healthTcp        []string
+	healthHttp       []string
+	healthHttpStatus string
+	healthInsecure   bool
+	healthFiles      []string
+	healthMaxAge     time.Duration
+	healthProcesses  []string
+	healthPidfiles   []string
+)
+
+func init() {
+	healthCmd.Flags().StringVarP(&healthConfigFile, "config", "c", "", "Config file (yaml or json) with health checks. Checks defined by flags are added.")
+	healthCmd.Flags().DurationVarP(&healthTimeout, "timeout", "t", 5*time.Second, "Timeout of each check, if not defined otherwise in the config file.")
+	healthCmd.Flags().StringArrayVar(&healthTcp, "tcp", []string{}, "Address (host:port) which must accept TCP connections.")
+	healthCmd.Flags().StringArrayVar(&healthHttp, "http", []string{}, "URL which must respond with an expected HTTP status code.")
+	healthCmd.Flags().StringVar(&healthHttpStatus, "http-status", defaultHttpStatus, "Expected HTTP status codes, as list of codes and ranges (e.g. 200,204 or 200-299).")
+	healthCmd.Flags().BoolVar(&healthInsecure, "insecure", false, "Skips the verification of TLS certificates for HTTP checks.")
+	healthCmd.Flags().StringArrayVar(&healthFiles, "file", []string{}, "File (glob pattern) which must exist and must have been modified within max-age.")
+	healthCmd.Flags().DurationVar(&healthMaxAge, "max-age", 0, "Maximum age of the modification time of files. If 0, only existence is checked. (default 0s)")
+	healthCmd.Flags().StringArrayVar(&healthProcesses, "process", []string{}, "Name of a process which must be running. Zombie processes and godub itself are ignored.")
+	healthCmd.Flags().StringArrayVar(&healthPidfiles, "pidfile", []string{}, "Pidfile which must contain the id of a running process, which is not a zombie.")
+	addOutputFlag(healthCmd)
+}
+
+const defaultHttpStatus string = "200-399"
+
+// healthConfig is the format of the config file.
+//
+//	timeout: 5s
+//	checks:
+//	  - tcp: localhost:9092
+//	  - http: http://localhost:8080/health
+//	    status: 200-299
+//	    timeout: 2s
+//	  - file: /tmp/heartbeat
+//	    maxAge: 30s
+//	  - process: java
+//	  - pidfile: /run/app.pid
+type healthConfig struct {
+	Timeout time.Duration `yaml:"timeout"`
+	Checks  []healthCheck `yaml:"checks"`
+}
+
+// healthCheck defines a single check. Exactly one of tcp, http, file, process and pidfile must be set.
+type healthCheck struct {
+	Name     string        `yaml:"name"`
+	Timeout  time.Duration `yaml:"timeout"`
+	Tcp      string        `yaml:"tcp"`
+	Http     string        `yaml:"http"`
+	Status   string        `yaml:"status"`
+	Insecure bool          `yaml:"insecure"`
+	File     string        `yaml:"file"`
+	MaxAge   time.Duration `yaml:"maxAge"`
+	Process  string        `yaml:"process"`
+	Pidfile  string        `yaml:"pidfile"`
+}
+
+func runHealthCmd(cmd *cobra.Command, args []string) error {
+	reporter, err := newReporter(cmd)
+	if err != nil {
+		return err
+	}
+	checks, err := healthChecksFromFlags()
+	if err != nil {
+		return reporter.finish(nil, usageError(err))
+	}
+	if len(checks) == 0 {
+		return reporter.finish(nil, usageError(fmt.Errorf("requires at least one check")))
+	}
+	return reporter.finish(runHealthChecks(checks))
+}
+
+func healthChecksFromFlags() ([]healthCheck, error) {
+	config := healthConfig{Timeout: healthTimeout, Checks: make([]healthCheck, 0)}
+	if healthConfigFile != "" {
+		content, err := os.ReadFile(healthConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read config file: %v", err)
+		}
+		if err := yaml.Unmarshal(content, &config); err != nil {
+			return nil, fmt.Errorf("could not parse config file %s: %v", healthConfigFile, err)
+		}
+	}
+	for _, address := range healthTcp {
+		config.Checks = append(config.Checks, healthCheck{Tcp: address})
+	}
+	for _, url := range healthHttp {
+		config.Checks = append(config.Checks, healthCheck{Http: url, Status: healthHttpStatus, Insecure: healthInsecure})
+	}
+	for _, file := range healthFiles {
+		config.Checks = append(config.Checks, healthCheck{File: file, MaxAge: healthMaxAge})
+	}
+	for _, process := range healthProcesses {
+		config.Checks = append(config.Checks, healthCheck{Process: process})
+	}
+	for _, pidfile := range healthPidfiles {
+		config.Checks = append(config.Checks, healthCheck{Pidfile: pidfile})
+	}
+	for i := range config.Checks {
+		check := &config.Checks[i]
+		if check.Timeout <= 0 {
+			check.Timeout = config.Timeout
+		}
+		if check.Timeout <= 0 {
+			return nil, fmt.Errorf("timeout must be positive, but was: %v", check.Timeout)
+		}
+		if check.Status == "" {
+			check.Status = defaultHttpStatus
+		}
+		if err := check.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return config.Checks, nil
+}
+
+func (c healthCheck) validate() error {
+	kinds := 0
+	for _, value := range []string{c.Tcp, c.Http, c.File, c.Process, c.Pidfile} {
+		if value != "" {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("check %s must define exactly one of [tcp, http, file, process, pidfile]", c.name())
+	}
+	if c.Http != "" {
+		if _, err := parseHttpStatus(c.Status); err != nil {
+			return fmt.Errorf("check %s has invalid status: %v", c.name(), err)
+		}
+	}
+	return nil
+}
+
+// name returns the name of the check, which is used as item in the report.
+func (c healthCheck) name() string {
+	switch {
+	case c.Name != "":
+		return c.Name
+	case c.Tcp != "":
+		return "tcp " + c.Tcp
+	case c.Http != "":
+		return "http " + c.Http
+	case c.File != "":
+		return "file " + c.File
+	case c.Process != "":
+		return "process " + c.Process
+	case c.Pidfile != "":
+		return "pidfile " + c.Pidfile
+	default:
+		return "<undefined>"
+	}
+}
+
+// runHealthChecks runs all checks in parallel. In contrast to other check commands, timeouts
+// result in exit status 1, because Docker only distinguishes between healthy (0) and unhealthy (1).
+func runHealthChecks(checks []healthCheck) ([]*checkResult, error) {
+	results := make([]*checkResult, len(checks))
+	var wg sync.WaitGroup
+	wg.Add(len(checks))
+	for i, check := range checks {
+		go func(i int, check healthCheck) {
+			defer wg.Done()
+			result := startCheck(check.name())
+			result.Attempts = 1
+			results[i] = result.complete(check.run())
+		}(i, check)
+	}
+	wg.Wait()
+	failed := make([]string, 0)
+	for _, result := range results {
+		if result.Status != statusPassed {
+			failed = append(failed, fmt.Sprintf("%s -> %s", result.Item, result.Reason))
+		}
+	}
+	if len(failed) > 0 {
+		return results, checkFailedError(fmt.Errorf("%d of %d checks failed:\n\t%s", len(failed), len(results), strings.Join(failed, "\n\t")))
+	}
+	return results, nil
+}
+
+func (c healthCheck) run() error {
+	var err error
+	switch {
+	case c.Tcp != "":
+		err = checkTcp(c.Tcp, c.Timeout)
+	case c.Http != "":
+		err = checkHttp(c.Http, c.Status, c.Insecure, c.Timeout)
+	case c.File != "":
+		err = checkFileFreshness(c.File, c.MaxAge)
+	case c.Process != "":
+		err = checkProcess(c.Process)
+	case c.Pidfile != "":
+		err = checkPidfile(c.Pidfile)
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return timedOutError(err)
+	}
+	if err != nil {
+		return checkFailedError(err)
+	}
+	return nil
+}
+
+func checkTcp(address string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func checkHttp(url string, status string, insecure bool, timeout time.Duration) error {
+	expected, err := parseHttpStatus(status)
+	if err != nil {
+		return err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: insecure}
+	client := &http.Client{Timeout: timeout, Transport: transport}
+	response, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	for _, statusRange := range expected {
+		if response.StatusCode >= statusRange[0] && response.StatusCode <= statusRange[1] {
+			return nil
+		}
+	}
+	return fmt.Errorf("status %d is not one of %s", response.StatusCode, status)
+}
+
+// parseHttpStatus parses a comma separated list of status codes and ranges (e.g. 200,300-399).
+func parseHttpStatus(status string) ([][2]int, error) {
+	ranges := make([][2]int, 0)
+	for _, part := range strings.Split(status, ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		if !isRange {
+			to = from
+		}
+		fromCode, fromErr := strconv.Atoi(from)
+		toCode, toErr := strconv.Atoi(to)
+		if fromErr != nil || toErr != nil || fromCode > toCode {
+			return nil, fmt.Errorf("status must be a list of codes and ranges (e.g. 200,300-399), but was: %s", status)
+		}
+		ranges = append(ranges, [2]int{fromCode, toCode})
+	}
+	return ranges, nil
+}
+
+// checkFileFreshness uses the same check as the path command and additionally
+// requires that all matching files were modified within max age.
+func checkFileFreshness(pattern string, maxAge time.Duration) error {
+	if err := checkPath(pattern, pathCondition{mode: unix.F_OK, minMatches: 1}, nil); err != nil {
+		return err
+	}
+	if maxAge <= 0 {
+		return nil
+	}
+	paths, err := expandPathPattern(pattern)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if age := time.Since(info.ModTime()); age > maxAge {
+			return fmt.Errorf("%s was modified %v ago, but max age is %v", path, age.Round(time.Second), maxAge)
+		}
+	}
+	return nil
+}
+
+// checkProcess checks if a process with the given name is running. The name is compared with
+// the basename of the executable in the command line and with the command name of the kernel.
+// Zombie and dead processes and the own process are ignored.
+func checkProcess(name string) error {
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return err
+	}
+	self := os.Getpid()
+	for _, proc := range procs {
+		if pid, err := strconv.Atoi(proc.Name()); err != nil || pid == self || !processAlive(pid) {
+			continue
+		}
+		if cmdline, err := os.ReadFile(filepath.Join("/proc", proc.Name(), "cmdline")); err == nil && len(cmdline) > 0 {
+			executable, _, _ := strings.Cut(string(cmdline), "\x00")
+			if filepath.Base(executable) == name {
+				return nil
+			}
+		}
+		if comm, err := os.ReadFile(filepath.Join("/proc", proc.Name(), "comm")); err == nil {
+			// the kernel truncates the command name to 15 characters
+			command := strings.TrimSuffix(string(comm), "\n")
+			if command == name || (len(name) > len(command) && len(command) == 15 && strings.HasPrefix(name, command)) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no process with name %s is running", name)
+}
+
+func checkPidfile(pidfile string) error {
+	content, err := os.ReadFile(pidfile)
+	if err != nil {
+		return err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || pid <= 0 {
+		return fmt.Errorf("%s does not contain a valid process id", pidfile)
+	}
+	// signal 0 only checks if the process exists, EPERM means it exists but belongs to another user
+	if err := unix.Kill(pid, 0); err != nil && err != unix.EPERM {
+		return fmt.Errorf("process %d is not running: %v", pid, err)
+	}
+	if !processAlive(pid) {
+		return fmt.Errorf("process %d is not running, it is a zombie", pid)
+	}
+	return nil
+}
+
+// processAlive checks the state of a process in /proc/<pid>/stat, which is neither zombie (Z) nor dead (X).
+// The state follows the command name in parentheses, which can contain spaces and parentheses itself.
+// If the state cannot be read, the process is considered alive.
+func processAlive(pid int) bool {
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return true
+	}
+	end := strings.LastIndexByte(string(stat), ')')
+	if end < 0 || end+2 >= len(stat) {
+		return true
+	}
+	switch stat[end+2] {
+	case 'Z', 'X', 'x':
+		return false
+	}
+	return true
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ueisele/go-docker-utils/pkg/template"
+)
+
+var (
+	keystoreCmd = &cobra.Command{
+		Use:   "keystore",
+		Short: "Creates a Java keystore or truststore of PEM certificates and keys.",
+		Long: "Creates a Java keystore or truststore of PEM certificates and keys, without keytool. " +
+			"With --cert and --key, a keystore with the private key is created, and the --ca certificates are appended to its chain. " +
+			"With only --ca, a truststore with the certificates is created. " +
+			"The password is read from --password, the environment variable --password-env or the file --password-file.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE:         runKeystoreCmd,
+	}
+	keystoreOut          string
+	keystoreType         string
+	keystoreCert         string
+	keystoreKey          string
+	keystoreCa           []string
+	keystoreAlias        string
+	keystorePassword     string
+	keystorePasswordEnv  string
+	keystorePasswordFile string
+)
+
+func init() {
+	keystoreCmd.Flags().StringVarP(&keystoreOut, "out", "o", "", "The keystore file, which is written with mode 0600.")
+	keystoreCmd.Flags().StringVarP(&keystoreType, "type", "t", "", "Keystore type, one of [pkcs12, jks]. If not provided, it is jks for files with extension .jks, and pkcs12 otherwise.")
+	keystoreCmd.Flags().StringVarP(&keystoreCert, "cert", "c", "", "PEM certificate, which can contain the chain.")
+	keystoreCmd.Flags().StringVarP(&keystoreKey, "key", "k", "", "PEM private key of the certificate.")
+	keystoreCmd.Flags().StringSliceVar(&keystoreCa, "ca", []string{}, "PEM CA certificates, can be glob patterns. The chain of the keystore, or the certificates of the truststore.")
+	keystoreCmd.Flags().StringVarP(&keystoreAlias, "alias", "a", "", "Alias of the entry. Default is mykey for jks keystores and caroot for truststores. Not supported for pkcs12 keystores, whose key entry is named 1 by Java.")
+	keystoreCmd.Flags().StringVarP(&keystorePassword, "password", "p", "", "Password of the keystore. An empty password (--password '') is only supported for pkcs12.")
+	keystoreCmd.Flags().StringVar(&keystorePasswordEnv, "password-env", "", "Environment variable with the password of the keystore.")
+	keystoreCmd.Flags().StringVar(&keystorePasswordFile, "password-file", "", "File with the password of the keystore. A trailing newline is removed.")
+}
+
+func runKeystoreCmd(cmd *cobra.Command, args []string) error {
+	if keystoreOut == "" {
+		return usageError(fmt.Errorf("--out is required"))
+	}
+	storeType := keystoreType
+	if storeType == "" {
+		storeType = template.KeystoreTypeOf(keystoreOut)
+	}
+	if storeType != template.KeystorePKCS12 && storeType != template.KeystoreJKS {
+		return usageError(fmt.Errorf("type must be one of %v, but was: %s", template.KeystoreTypes, storeType))
+	}
+	if (keystoreCert == "") != (keystoreKey == "") {
+		return usageError(fmt.Errorf("--cert and --key must be provided together"))
+	}
+	if keystoreCert == "" && len(keystoreCa) == 0 {
+		return usageError(fmt.Errorf("either --cert and --key or --ca is required"))
+	}
+	if keystoreCert != "" && keystoreAlias != "" && storeType == template.KeystorePKCS12 {
+		return usageError(fmt.Errorf("--alias is not supported for pkcs12 keystores, whose key entry is named 1 by Java, use --type jks instead"))
+	}
+	password, err := keystorePasswordValue(cmd)
+	if err != nil {
+		return usageError(err)
+	}
+	caCerts, err := readCertificateFiles(keystoreCa...)
+	if err != nil {
+		return err
+	}
+
+	var store []byte
+	if keystoreCert != "" {
+		certs, err := readCertificateFiles(keystoreCert)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(keystoreKey)
+		if err != nil {
+			return fmt.Errorf("could not read key: %v", err)
+		}
+		key, err := template.ParsePrivateKey(content)
+		if err != nil {
+			return fmt.Errorf("could not parse key %s: %v", keystoreKey, err)
+		}
+		store, err = template.NewKeystore(storeType, keystoreAlias, key, append(certs, caCerts...), password)
+		if err != nil {
+			return err
+		}
+	} else {
+		store, err = template.NewTruststore(storeType, aliasOrDefault("caroot"), caCerts, password)
+		if err != nil {
+			return err
+		}
+	}
+	return writePrivateFile(keystoreOut, store)
+}
+
+// writePrivateFile writes the file with mode 0600, also if it already exists with another mode.
+// The content is written to a temporary file, which is created with mode 0600, and renamed.
+func writePrivateFile(filename string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+"-*")
+	if err != nil {
+		return fmt.Errorf("could not write %s: %v", filename, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write %s: %v", filename, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write %s: %v", filename, err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("could not write %s: %v", filename, err)
+	}
+	return nil
+}
+
+// keystorePasswordValue returns the password of exactly one of the password flags. The password
+// flag can be set to an empty password.
+func keystorePasswordValue(cmd *cobra.Command) (string, error) {
+	provided := 0
+	for _, flag := range []string{"password", "password-env", "password-file"} {
+		if cmd.Flags().Changed(flag) {
+			provided++
+		}
+	}
+	if provided != 1 {
+		return "", fmt.Errorf("exactly one of --password, --password-env and --password-file is required")
+	}
+	switch {
+	case cmd.Flags().Changed("password-env"):
+		password, ok := os.LookupEnv(keystorePasswordEnv)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", keystorePasswordEnv)
+		}
+		return password, nil
+	case cmd.Flags().Changed("password-file"):
+		content, err := os.ReadFile(keystorePasswordFile)
+		if err != nil {
+			return "", fmt.Errorf("could not read password: %v", err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), nil
+	default:
+		return keystorePassword, nil
+	}
+}
+
+func aliasOrDefault(defaultAlias string) string {
+	if keystoreAlias != "" {
+		return keystoreAlias
+	}
+	return defaultAlias
+}
+
+// readCertificateFiles reads all certificates of the files matching the patterns, in order.
+func readCertificateFiles(patterns ...string) ([]*x509.Certificate, error) {
+	certs := make([]*x509.Certificate, 0)
+	for _, pattern := range patterns {
+		filenames, err := template.FileGlobsToFileNames(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(filenames) == 0 {
+			return nil, fmt.Errorf("%s matches no files", pattern)
+		}
+		for _, filename := range filenames {
+			content, err := os.ReadFile(filename)
+			if err != nil {
+				return nil, err
+			}
+			parsed, err := template.ParseCertificates(content)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %s: %v", filename, err)
+			}
+			certs = append(certs, parsed...)
+		}
+	}
+	return certs, nil
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ueisele/go-docker-utils/pkg/template"
+)
+
+var (
+	patchCmd = &cobra.Command{
+		Use:   "patch file",
+		Short: "Sets and deletes values of existing yaml, json, toml and properties files.",
+		Long: "Sets and deletes values of existing yaml, json, toml and properties files and writes them back in their format. " +
+			"Comments and the order of keys are preserved for yaml and properties, and the order of keys for json. " +
+			"Merge patches are applied first, then JSON patches, then --set, --set-string and at last --delete.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         runPatchCmd,
+	}
+	patchFormat      string
+	patchOut         string
+	patchSet         []string
+	patchSetString   []string
+	patchDelete      []string
+	patchMergePatch  []string
+	patchJSONPatch   []string
+	patchIgnoreEmpty bool
+)
+
+func init() {
+	patchCmd.Flags().StringVar(&patchFormat, "format", "", "Format of the file, one of [yaml, json, toml, properties]. If not provided, it is determined by the extension.")
+	patchCmd.Flags().StringVarP(&patchOut, "out", "o", "", "The output file. If not provided, the file is patched in place. Use '-' for stdout.")
+	patchCmd.Flags().StringArrayVarP(&patchSet, "set", "s", []string{}, "Sets a value (path=value). The value is parsed as yaml (e.g. 1, true, [a, b]). The path is dotted (a.b.0) or a JSON pointer (/a/b/0).")
+	patchCmd.Flags().StringArrayVar(&patchSetString, "set-string", []string{}, "Sets a string value (path=value).")
+	patchCmd.Flags().StringArrayVarP(&patchDelete, "delete", "d", []string{}, "Deletes the value at the path. Missing paths are ignored.")
+	patchCmd.Flags().StringArrayVar(&patchMergePatch, "merge-patch", []string{}, "Applies a JSON merge patch (RFC 7396) from a yaml or json file.")
+	patchCmd.Flags().StringArrayVar(&patchJSONPatch, "json-patch", []string{}, "Applies a JSON patch (RFC 6902) from a yaml or json file.")
+	patchCmd.Flags().BoolVar(&patchIgnoreEmpty, "ignore-empty", false, "Ignores --set and --set-string with empty values, which is helpful with unset environment variables.")
+}
+
+func runPatchCmd(cmd *cobra.Command, args []string) error {
+	filename := args[0]
+	format := patchFormat
+	if format == "" {
+		var err error
+		if format, err = template.FormatOf(filename); err != nil {
+			return usageError(err)
+		}
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("could not read %s: %v", filename, err)
+	}
+	doc, err := template.LoadDocument(format, content)
+	if err != nil {
+		return fmt.Errorf("could not load %s: %v", filename, err)
+	}
+
+	for _, patchFile := range patchMergePatch {
+		patch, err := loadPatchFile(patchFile)
+		if err != nil {
+			return err
+		}
+		if err := doc.MergePatch(patch); err != nil {
+			return fmt.Errorf("could not apply merge patch %s: %v", patchFile, err)
+		}
+	}
+	for _, patchFile := range patchJSONPatch {
+		patch, err := loadPatchFile(patchFile)
+		if err != nil {
+			return err
+		}
+		if err := doc.JSONPatch(patch); err != nil {
+			return fmt.Errorf("could not apply JSON patch %s: %v", patchFile, err)
+		}
+	}
+	if err := applySetFlags(doc, patchSet, true); err != nil {
+		return err
+	}
+	if err := applySetFlags(doc, patchSetString, false); err != nil {
+		return err
+	}
+	for _, path := range patchDelete {
+		if _, err := doc.Delete(path); err != nil {
+			return fmt.Errorf("could not delete %s: %v", path, err)
+		}
+	}
+
+	patched, err := doc.Encode()
+	if err != nil {
+		return fmt.Errorf("could not write %s: %v", format, err)
+	}
+	switch patchOut {
+	case "-":
+		_, err = cmd.OutOrStdout().Write(patched)
+		return err
+	case "":
+		return writeFileKeepMode(filename, patched)
+	default:
+		return writeFileKeepMode(patchOut, patched)
+	}
+}
+
+func applySetFlags(doc *template.Document, assignments []string, typed bool) error {
+	for _, assignment := range assignments {
+		path, text, ok := strings.Cut(assignment, "=")
+		if !ok || path == "" {
+			return usageError(fmt.Errorf("set must have the format path=value, but was: %s", assignment))
+		}
+		if text == "" && patchIgnoreEmpty {
+			continue
+		}
+		var value interface{} = text
+		if typed {
+			var err error
+			if value, err = template.ParseValue(text); err != nil {
+				return usageError(err)
+			}
+		}
+		if err := doc.Set(path, value); err != nil {
+			return fmt.Errorf("could not set %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+func loadPatchFile(filename string) (interface{}, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not read patch %s: %v", filename, err)
+	}
+	patch, err := template.ParseValue(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse patch %s: %v", filename, err)
+	}
+	return patch, nil
+}
+
+// writeFileKeepMode writes a file and keeps the mode of an existing file.
+func writeFileKeepMode(filename string, content []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(filename, content, mode); err != nil {
+		return fmt.Errorf("could not write %s: %v", filename, err)
+	}
+	return nil
+}
@@ -1,18 +1,28 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
+
 	"golang.org/x/sys/unix"
 
 	"github.com/spf13/cobra"
+
+	"github.com/ueisele/go-docker-utils/pkg/template"
 )
 
 var (
 	pathCmd = &cobra.Command{
 		Use:   "path",
 		Short: "Checks a path on the filesystem for permissions.",
-		Long:  "Checks a path on the filesystem for permissions.",
+		Long:  "Checks a path on the filesystem for permissions. Paths can be glob patterns. Optionally, missing paths are created and mode and owner are fixed before the check.",
 		SilenceUsage: true,
 		RunE:  runPathCmd,
 	}
@@ -23,36 +33,212 @@ var (
 	timeout		time.Duration
 )
 
+var (
+	create       bool
+	createType   string
+	createMode   string
+	owner        string
+	recursive    bool
+	minMatches   int
+	stable       time.Duration
+	pollInterval time.Duration
+)
+
+const (
+	pathTypeDir  string = "dir"
+	pathTypeFile string = "file"
+)
+
 func init() {
 	pathCmd.Flags().BoolVarP(&existence, "existence", "e", true, "Path must be existence")
 	pathCmd.Flags().BoolVarP(&readable, "readable", "r", false, "Path must be readable")
 	pathCmd.Flags().BoolVarP(&writeable, "writeable", "w", false, "Path must be writeable")
 	pathCmd.Flags().BoolVarP(&executable, "executable", "x", false, "Path must be executable")
 	pathCmd.Flags().DurationVarP(&timeout, "timeout", "t", 0, " Time to wait for the URL to be retrievable (default 0s)")
+	pathCmd.Flags().BoolVarP(&create, "create", "c", false, "Creates missing paths (including parent directories) before the check.")
+	pathCmd.Flags().StringVar(&createType, "type", pathTypeDir, fmt.Sprintf("Type of the paths to create, one of [%s, %s].", pathTypeDir, pathTypeFile))
+	pathCmd.Flags().StringVarP(&createMode, "mode", "m", "", "Permission bits in octal notation (e.g. 0750), which are set on the paths.")
+	pathCmd.Flags().StringVar(&owner, "owner", "", "Owner of the paths in the format user[:group]. User and group can be names or numeric ids.")
+	pathCmd.Flags().BoolVarP(&recursive, "recursive", "R", false, "Changes the owner recursively for all files and directories below the paths.")
+	pathCmd.Flags().IntVarP(&minMatches, "min-matches", "n", 1, "Minimum number of paths each glob pattern must match.")
+	pathCmd.Flags().DurationVarP(&stable, "stable", "s", 0, "Duration for which size and modification time of matching paths must not have changed (default 0s)")
+	pathCmd.Flags().DurationVarP(&pollInterval, "poll-interval", "p", 100*time.Millisecond, "Maximum time between two checks while waiting. Changes are usually detected immediately using inotify, the interval applies if this is not possible.")
+	addOutputFlag(pathCmd)
 }
 
 func runPathCmd(cmd *cobra.Command, args []string) error {
+	reporter, err := newReporter(cmd)
+	if err != nil {
+		return err
+	}
 	if len(args) == 0 {
-		return fmt.Errorf("requires at least one path as argument")
+		return reporter.finish(nil, usageError(fmt.Errorf("requires at least one path as argument")))
 	}
-	return checkPathPermissions(cmd.Flags().Args(), flagsToMode(), timeout)
+	if pollInterval <= 0 {
+		return reporter.finish(nil, usageError(fmt.Errorf("poll-interval must be positive, but was: %v", pollInterval)))
+	}
+	if minMatches < 1 {
+		return reporter.finish(nil, usageError(fmt.Errorf("min-matches must be at least 1, but was: %d", minMatches)))
+	}
+	options, err := flagsToPrepareOptions()
+	if err != nil {
+		return reporter.finish(nil, usageError(err))
+	}
+	if options.enabled() {
+		if err := preparePaths(reporter, cmd.Flags().Args(), options); err != nil {
+			return reporter.finish(nil, checkFailedError(err))
+		}
+	}
+	return reporter.finish(checkPathPermissions(cmd.Flags().Args(), pathCondition{mode: flagsToMode(), minMatches: minMatches, stable: stable}, timeout, pollInterval))
+}
+
+// pathCondition describes what is required for the paths matching a pattern.
+type pathCondition struct {
+	mode       uint32
+	minMatches int
+	stable     time.Duration
 }
 
-func checkPathPermissions(paths []string, mode uint32, timeout time.Duration) error {
+// checkPathPermissions waits until all patterns fulfill the condition or the timeout
+// is exceeded. If a pattern fails, the remaining patterns are checked only once.
+func checkPathPermissions(patterns []string, condition pathCondition, timeout time.Duration, pollInterval time.Duration) ([]*checkResult, error) {
 	tryUntil := time.Now().Add(timeout)
-	for i := 0; i < len(paths); {
-		err := unix.Access(paths[i], mode)
-		if err == nil {
-			i++
-		} else if time.Now().Before(tryUntil) {
-			time.Sleep(100 * time.Millisecond)
-		} else {
-			return fmt.Errorf("%v -> %v", paths[i], err)
+	stability := newStabilityTracker()
+	var watcher pathWatcher
+	defer func() {
+		if watcher != nil {
+			watcher.close()
+		}
+	}()
+	results := make([]*checkResult, 0, len(patterns))
+	var failed error
+	for _, pattern := range patterns {
+		result := startCheck(pattern)
+		for {
+			result.Attempts++
+			err := checkPath(pattern, condition, stability)
+			if err == nil {
+				result.complete(nil)
+				break
+			}
+			if remaining := time.Until(tryUntil); failed == nil && remaining > 0 {
+				if watcher == nil {
+					watcher = newPathWatcher(pollInterval)
+				}
+				var notStable *notStableError
+				if errors.As(err, &notStable) {
+					remaining = minDuration(remaining, notStable.remaining)
+				}
+				watcher.wait(pattern, remaining)
+				continue
+			}
+			if timeout > 0 && result.Attempts > 1 {
+				err = timedOutError(err)
+			} else {
+				err = checkFailedError(err)
+			}
+			result.complete(err)
+			if failed == nil {
+				failed = fmt.Errorf("%v -> %w", pattern, err)
+			}
+			break
+		}
+		results = append(results, result)
+	}
+	return results, failed
+}
+
+func checkPath(pattern string, condition pathCondition, stability *stabilityTracker) error {
+	paths, err := expandPathPattern(pattern)
+	if err != nil {
+		return err
+	}
+	if isPathPattern(pattern) && len(paths) < condition.minMatches {
+		return fmt.Errorf("%d paths match, but at least %d are required", len(paths), condition.minMatches)
+	}
+	for _, path := range paths {
+		if err := unix.Access(path, condition.mode); err != nil {
+			if isPathPattern(pattern) {
+				return fmt.Errorf("%v -> %v", path, err)
+			}
+			return err
+		}
+		if condition.stable > 0 {
+			if err := stability.check(path, condition.stable); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
+// isPathPattern checks if the path contains any of the magic characters
+// recognized by filepath.Match.
+func isPathPattern(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+// expandPathPattern returns the paths matching a glob pattern. A path which is
+// not a pattern is returned as is, regardless of whether it exists.
+func expandPathPattern(pattern string) ([]string, error) {
+	if !isPathPattern(pattern) {
+		return []string{pattern}, nil
+	}
+	paths, err := template.FileGlobsToFileNames(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse glob: %v", err)
+	}
+	return paths, nil
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+	since   time.Time
+}
+
+// stabilityTracker remembers size and modification time of paths in order to
+// detect if a file is still being written.
+type stabilityTracker struct {
+	states map[string]fileState
+}
+
+func newStabilityTracker() *stabilityTracker {
+	return &stabilityTracker{states: make(map[string]fileState)}
+}
+
+// check returns an error if size or modification time of the path changed within
+// the given duration. On first sight, the modification time is assumed as the
+// time of the last change.
+func (t *stabilityTracker) check(path string, duration time.Duration) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	state, seen := t.states[path]
+	if !seen {
+		state = fileState{size: info.Size(), modTime: info.ModTime(), since: info.ModTime()}
+	} else if state.size != info.Size() || !state.modTime.Equal(info.ModTime()) {
+		state = fileState{size: info.Size(), modTime: info.ModTime(), since: time.Now()}
+	}
+	t.states[path] = state
+	if unchanged := time.Since(state.since); unchanged < duration {
+		return &notStableError{unchanged: unchanged, remaining: duration - unchanged}
+	}
+	return nil
+}
+
+// notStableError is returned if a path changed recently. Because no further change is
+// expected, it tells how long to wait until the path can be considered stable.
+type notStableError struct {
+	unchanged time.Duration
+	remaining time.Duration
+}
+
+func (e *notStableError) Error() string {
+	return fmt.Sprintf("not stable, last change was %v ago but %v are required", e.unchanged.Round(time.Millisecond), e.unchanged+e.remaining)
+}
+
 func flagsToMode() uint32 {
 	var mode uint32
 	mode = 0
@@ -60,4 +246,226 @@ func flagsToMode() uint32 {
 	if (writeable) { mode = mode | unix.W_OK }
 	if (executable) { mode = mode | unix.X_OK }
 	return mode
-}
\ No newline at end of file
+}
+
+type prepareOptions struct {
+	create    bool
+	pathType  string
+	mode      *fs.FileMode
+	uid       int
+	gid       int
+	recursive bool
+}
+
+func (o prepareOptions) enabled() bool {
+	return o.create || o.mode != nil || o.uid >= 0 || o.gid >= 0
+}
+
+func flagsToPrepareOptions() (prepareOptions, error) {
+	options := prepareOptions{create: create, pathType: createType, uid: -1, gid: -1, recursive: recursive}
+	if createType != pathTypeDir && createType != pathTypeFile {
+		return options, fmt.Errorf("type must be one of [%s, %s], but was: %s", pathTypeDir, pathTypeFile, createType)
+	}
+	if createMode != "" {
+		mode, err := parseFileMode(createMode)
+		if err != nil {
+			return options, err
+		}
+		options.mode = &mode
+	}
+	if owner != "" {
+		uid, gid, err := parseOwner(owner)
+		if err != nil {
+			return options, err
+		}
+		options.uid, options.gid = uid, gid
+	}
+	if recursive && options.uid < 0 && options.gid < 0 {
+		return options, fmt.Errorf("recursive requires an owner")
+	}
+	return options, nil
+}
+
+func parseFileMode(text string) (fs.FileMode, error) {
+	mode, err := strconv.ParseUint(text, 8, 32)
+	if err != nil || mode > 07777 {
+		return 0, fmt.Errorf("mode must be an octal number between 0000 and 7777, but was: %s", text)
+	}
+	return unixToFileMode(uint32(mode)), nil
+}
+
+// unixToFileMode converts unix permission bits including setuid, setgid and sticky
+// into the representation used by the os package.
+func unixToFileMode(mode uint32) fs.FileMode {
+	fileMode := fs.FileMode(mode & 0777)
+	if mode&unix.S_ISUID != 0 {
+		fileMode |= fs.ModeSetuid
+	}
+	if mode&unix.S_ISGID != 0 {
+		fileMode |= fs.ModeSetgid
+	}
+	if mode&unix.S_ISVTX != 0 {
+		fileMode |= fs.ModeSticky
+	}
+	return fileMode
+}
+
+// parseOwner resolves an owner in the format user[:group] to numeric ids.
+// A missing user or group is returned as -1, which means it is not changed.
+// Like chown, a user followed by a colon without group gets the login group of the user,
+// which is also used for user names without group.
+func parseOwner(text string) (uid int, gid int, err error) {
+	userPart, groupPart, hasGroup := strings.Cut(text, ":")
+	uid, gid = -1, -1
+	if userPart != "" {
+		var u *user.User
+		if uid, err = strconv.Atoi(userPart); err != nil {
+			var lookupErr error
+			if u, lookupErr = user.Lookup(userPart); lookupErr != nil {
+				return -1, -1, fmt.Errorf("could not resolve user %s: %v", userPart, lookupErr)
+			}
+			if uid, err = strconv.Atoi(u.Uid); err != nil {
+				return -1, -1, fmt.Errorf("user %s has no numeric id: %v", userPart, err)
+			}
+		} else if hasGroup && groupPart == "" {
+			var lookupErr error
+			if u, lookupErr = user.LookupId(userPart); lookupErr != nil {
+				return -1, -1, fmt.Errorf("could not resolve login group of user %s: %v", userPart, lookupErr)
+			}
+		}
+		if u != nil && groupPart == "" {
+			if gid, err = strconv.Atoi(u.Gid); err != nil {
+				return -1, -1, fmt.Errorf("user %s has no numeric group id: %v", userPart, err)
+			}
+		}
+	}
+	if groupPart != "" {
+		if gid, err = strconv.Atoi(groupPart); err != nil {
+			g, lookupErr := user.LookupGroup(groupPart)
+			if lookupErr != nil {
+				return -1, -1, fmt.Errorf("could not resolve group %s: %v", groupPart, lookupErr)
+			}
+			if gid, err = strconv.Atoi(g.Gid); err != nil {
+				return -1, -1, fmt.Errorf("group %s has no numeric id: %v", groupPart, err)
+			}
+		}
+	}
+	return uid, gid, nil
+}
+
+// preparePaths creates missing paths and fixes mode and owner of the given paths.
+// Every change is reported to the writer.
+// Glob patterns are expanded, therefore only matching paths are considered.
+func preparePaths(report *reporter, patterns []string, options prepareOptions) error {
+	for _, pattern := range patterns {
+		paths, err := expandPathPattern(pattern)
+		if err != nil {
+			return fmt.Errorf("%v -> %v", pattern, err)
+		}
+		for _, path := range paths {
+			if err := preparePath(report, path, options); err != nil {
+				return fmt.Errorf("%v -> %v", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+func preparePath(report *reporter, path string, options prepareOptions) error {
+	_, err := os.Lstat(path)
+	if os.IsNotExist(err) && options.create {
+		if err := createPath(path, options.pathType); err != nil {
+			return err
+		}
+		if options.pathType == pathTypeFile {
+			report.changed(path, "created file")
+		} else {
+			report.changed(path, "created directory")
+		}
+	} else if err != nil {
+		return err
+	}
+	if options.mode != nil {
+		if err := changeMode(report, path, *options.mode); err != nil {
+			return err
+		}
+	}
+	if options.uid >= 0 || options.gid >= 0 {
+		if !options.recursive {
+			return changeOwner(report, path, options.uid, options.gid)
+		}
+		return filepath.WalkDir(path, func(subpath string, _ fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			return changeOwner(report, subpath, options.uid, options.gid)
+		})
+	}
+	return nil
+}
+
+func createPath(path string, pathType string) error {
+	if pathType == pathTypeFile {
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+		if err != nil {
+			return err
+		}
+		return file.Close()
+	}
+	return os.MkdirAll(path, 0777)
+}
+
+func changeMode(report *reporter, path string, mode fs.FileMode) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	current := info.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+	if current == mode {
+		return nil
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		return err
+	}
+	report.changed(path, fmt.Sprintf("changed mode from %04o to %04o", fileModeToUnix(current), fileModeToUnix(mode)))
+	return nil
+}
+
+func fileModeToUnix(mode fs.FileMode) uint32 {
+	unixMode := uint32(mode & fs.ModePerm)
+	if mode&fs.ModeSetuid != 0 {
+		unixMode |= unix.S_ISUID
+	}
+	if mode&fs.ModeSetgid != 0 {
+		unixMode |= unix.S_ISGID
+	}
+	if mode&fs.ModeSticky != 0 {
+		unixMode |= unix.S_ISVTX
+	}
+	return unixMode
+}
+
+func changeOwner(report *reporter, path string, uid int, gid int) error {
+	var stat unix.Stat_t
+	if err := unix.Lstat(path, &stat); err != nil {
+		return err
+	}
+	targetUid, targetGid := int(stat.Uid), int(stat.Gid)
+	if uid >= 0 {
+		targetUid = uid
+	}
+	if gid >= 0 {
+		targetGid = gid
+	}
+	if targetUid == int(stat.Uid) && targetGid == int(stat.Gid) {
+		return nil
+	}
+	if err := os.Lchown(path, targetUid, targetGid); err != nil {
+		return err
+	}
+	report.changed(path, fmt.Sprintf("changed owner from %d:%d to %d:%d", stat.Uid, stat.Gid, targetUid, targetGid))
+	return nil
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ueisele/go-docker-utils/pkg/template"
+)
+
+var (
+	queryCmd = &cobra.Command{
+		Use:   "query expression [files...]",
+		Short: "Applies a JSONPath expression to yaml, json, toml and properties files.",
+		Long: "Applies a JSONPath expression to yaml, json, toml and properties files and prints the result. " +
+			"Multiple files are merged in order, like values files of the template command. If no file is provided, it is read from stdin.",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE:         runQueryCmd,
+	}
+	queryFrom   string
+	queryTo     string
+	queryExists bool
+)
+
+func init() {
+	queryCmd.Flags().StringVar(&queryFrom, "from", "", "Input format, one of [yaml, json, toml, properties]. If not provided, it is determined by the extension, and stdin is read as yaml, which includes json.")
+	queryCmd.Flags().StringVar(&queryTo, "to", outputText,
+		"Output format, one of [text, yaml, json, toml, properties]. Text prints scalars as they are, lists line by line and maps as json.")
+	queryCmd.Flags().BoolVarP(&queryExists, "exists", "e", false, "Fails with exit status 1 if the result is null or empty.")
+}
+
+func runQueryCmd(cmd *cobra.Command, args []string) error {
+	path, err := template.CompileJSONPath(args[0])
+	if err != nil {
+		return usageError(err)
+	}
+	if queryTo != outputText {
+		if err := template.CheckFormat(queryTo); err != nil {
+			return usageError(fmt.Errorf("to must be one of [%s, yaml, json, toml, properties], but was: %s", outputText, queryTo))
+		}
+	}
+	inputs, err := loadStructuredFiles(args[1:], queryFrom, cmd.InOrStdin())
+	if err != nil {
+		return err
+	}
+	data, err := mergeStructuredFiles(inputs)
+	if err != nil {
+		return err
+	}
+	result := path.Query(data)
+	if err := writeStructured(cmd.OutOrStdout(), queryTo, result); err != nil {
+		return err
+	}
+	if queryExists && isEmptyResult(result) {
+		return checkFailedError(fmt.Errorf("%s does not match any value", args[0]))
+	}
+	return nil
+}
+
+func isEmptyResult(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if list := reflect.ValueOf(value); list.Kind() == reflect.Slice {
+		return list.Len() == 0
+	}
+	return false
+}
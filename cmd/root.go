@@ -1,6 +1,12 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
 	"github.com/spf13/cobra"
 )
 
@@ -11,15 +17,202 @@ var (
 		Long:              "GoDub is a tool inspired by the Confluent Docker utility belt which contains a set of utility functions helpful for running containers.",
 		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
 	}
+	outputFormat string
 )
 
 func Execute(version string) error {
 	rootCmd.Version = version
-	return rootCmd.Execute()
+	cmd, err := rootCmd.ExecuteC()
+	if err != nil && !cmd.Runnable() {
+		// commands without run function, like the root command, only fail for unknown commands
+		return usageError(err)
+	}
+	return err
 }
 
 func init() {
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError(err)
+	})
 	rootCmd.AddCommand(renderCmd)
 	rootCmd.AddCommand(ensureCmd)
 	rootCmd.AddCommand(pathCmd)
+	rootCmd.AddCommand(healthCmd)
+	rootCmd.AddCommand(envnamesCmd)
+	rootCmd.AddCommand(patchCmd)
+	rootCmd.AddCommand(queryCmd)
+	rootCmd.AddCommand(convertCmd)
+	rootCmd.AddCommand(tlsCmd)
+	rootCmd.AddCommand(keystoreCmd)
+	for _, cmd := range rootCmd.Commands() {
+		if validateArgs := cmd.Args; validateArgs != nil {
+			cmd.Args = func(cmd *cobra.Command, args []string) error {
+				if err := validateArgs(cmd, args); err != nil {
+					return usageError(err)
+				}
+				return nil
+			}
+		}
+	}
+}
+
+// Exit codes returned by ExitCode.
+const (
+	ExitCheckFailed int = 1
+	ExitUsageError  int = 2
+	ExitTimedOut    int = 3
+)
+
+// exitError attaches an exit code to an error.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func usageError(err error) error {
+	return &exitError{code: ExitUsageError, err: err}
+}
+
+func checkFailedError(err error) error {
+	return &exitError{code: ExitCheckFailed, err: err}
+}
+
+func timedOutError(err error) error {
+	return &exitError{code: ExitTimedOut, err: err}
+}
+
+// ExitCode returns the exit code for an error returned by Execute.
+// Errors without a specific exit code result in 1.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	return 1
+}
+
+const (
+	outputText string = "text"
+	outputJson string = "json"
+)
+
+// addOutputFlag adds the output flag, which is shared by all check commands.
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&outputFormat, "output", outputText, fmt.Sprintf("Output format of the check result, one of [%s, %s].", outputText, outputJson))
+}
+
+type checkStatus string
+
+const (
+	statusPassed   checkStatus = "passed"
+	statusFailed   checkStatus = "failed"
+	statusTimedOut checkStatus = "timeout"
+	statusUsage    checkStatus = "usage"
+)
+
+func statusOf(err error) checkStatus {
+	switch ExitCode(err) {
+	case 0:
+		return statusPassed
+	case ExitUsageError:
+		return statusUsage
+	case ExitTimedOut:
+		return statusTimedOut
+	default:
+		return statusFailed
+	}
+}
+
+// checkResult is the result of a single item checked by a command.
+type checkResult struct {
+	Item      string      `json:"item"`
+	Status    checkStatus `json:"status"`
+	Reason    string      `json:"reason,omitempty"`
+	Value     string      `json:"value,omitempty"`
+	ElapsedMs int64       `json:"elapsedMs"`
+	Attempts  int         `json:"attempts"`
+	start     time.Time
+}
+
+func startCheck(item string) *checkResult {
+	return &checkResult{Item: item, start: time.Now()}
+}
+
+// complete records the outcome of the check. A nil error means the check passed.
+func (r *checkResult) complete(err error) *checkResult {
+	r.Status = statusOf(err)
+	if err != nil {
+		r.Reason = err.Error()
+	}
+	r.ElapsedMs = time.Since(r.start).Milliseconds()
+	return r
+}
+
+type checkReport struct {
+	Command   string         `json:"command"`
+	Status    checkStatus    `json:"status"`
+	Error     string         `json:"error,omitempty"`
+	ElapsedMs int64          `json:"elapsedMs"`
+	Changes   []string       `json:"changes,omitempty"`
+	Items     []*checkResult `json:"items"`
+}
+
+// reporter prints the result of a check command in the requested output format.
+// In text format only changes are printed, the error is printed by cobra.
+type reporter struct {
+	format string
+	out    io.Writer
+	report checkReport
+	start  time.Time
+}
+
+func newReporter(cmd *cobra.Command) (*reporter, error) {
+	if outputFormat != outputText && outputFormat != outputJson {
+		return nil, usageError(fmt.Errorf("output must be one of [%s, %s], but was: %s", outputText, outputJson, outputFormat))
+	}
+	return &reporter{
+		format: outputFormat,
+		out:    cmd.OutOrStdout(),
+		report: checkReport{Command: cmd.Name(), Items: make([]*checkResult, 0)},
+		start:  time.Now(),
+	}, nil
+}
+
+// changed reports a change which was applied to an item.
+func (r *reporter) changed(item string, change string) {
+	if r.format == outputJson {
+		r.report.Changes = append(r.report.Changes, fmt.Sprintf("%s: %s", item, change))
+	} else {
+		fmt.Fprintf(r.out, "%s: %s\n", item, change)
+	}
+}
+
+// finish writes the report and returns the error, so that it can be returned by the command.
+func (r *reporter) finish(results []*checkResult, err error) error {
+	r.report.Items = append(r.report.Items, results...)
+	r.report.Status = statusOf(err)
+	if err != nil {
+		r.report.Error = err.Error()
+	}
+	r.report.ElapsedMs = time.Since(r.start).Milliseconds()
+	if r.format == outputJson {
+		encoder := json.NewEncoder(r.out)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		if encErr := encoder.Encode(r.report); encErr != nil && err == nil {
+			return encErr
+		}
+	}
+	return err
 }
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/ueisele/go-docker-utils/pkg/template"
+)
+
+// structuredFile is the decoded content of a yaml, json, toml or properties file.
+type structuredFile struct {
+	name string
+	data interface{}
+}
+
+// loadStructuredFiles loads files (glob patterns) in any supported format. The format is determined by the
+// extension, if it is not provided. Without files, stdin is read, by default as yaml.
+func loadStructuredFiles(patterns []string, format string, stdin io.Reader) ([]structuredFile, error) {
+	if format != "" {
+		if err := template.CheckFormat(format); err != nil {
+			return nil, usageError(err)
+		}
+	}
+	if len(patterns) == 0 {
+		if format == "" {
+			format = template.FormatYAML
+		}
+		content, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("could not read stdin: %v", err)
+		}
+		data, err := template.Decode(format, string(content))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse stdin: %v", err)
+		}
+		return []structuredFile{{name: "stdin", data: data}}, nil
+	}
+	filenames, err := template.FileGlobsToFileNames(patterns...)
+	if err != nil {
+		return nil, usageError(fmt.Errorf("could not parse input glob: %v", err))
+	}
+	if len(filenames) == 0 {
+		return nil, usageError(fmt.Errorf("input globs matches no files: %#q", patterns))
+	}
+	files := make([]structuredFile, 0, len(filenames))
+	for _, filename := range filenames {
+		fileFormat := format
+		if fileFormat == "" {
+			if fileFormat, err = template.FormatOf(filename); err != nil {
+				return nil, usageError(err)
+			}
+		}
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %v", filename, err)
+		}
+		data, err := template.Decode(fileFormat, string(content))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s: %v", filename, err)
+		}
+		files = append(files, structuredFile{name: filename, data: data})
+	}
+	return files, nil
+}
+
+// mergeStructuredFiles merges the content of files in order, like values files of the template command.
+// A single file can contain any value, multiple files must contain maps.
+func mergeStructuredFiles(files []structuredFile) (interface{}, error) {
+	if len(files) == 1 {
+		return files[0].data, nil
+	}
+	contextBuilder := template.NewContextBuilder()
+	for _, file := range files {
+		data, ok := file.data.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s must contain a map to be merged, but was %T", file.name, file.data)
+		}
+		contextBuilder.WithMap(data)
+	}
+	return contextBuilder.Build()
+}
+
+// writeStructured writes a value in a supported format or as text. The format must have been checked before.
+func writeStructured(out io.Writer, format string, value interface{}) error {
+	if format != outputText {
+		text, err := template.Encode(format, value)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, strings.TrimSuffix(text, "\n"))
+		return err
+	}
+	values := []interface{}{value}
+	if list := reflect.ValueOf(value); list.Kind() == reflect.Slice {
+		values = make([]interface{}, 0, list.Len())
+		for i := 0; i < list.Len(); i++ {
+			values = append(values, list.Index(i).Interface())
+		}
+	}
+	for _, v := range values {
+		line, err := textOf(v)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func textOf(value interface{}) (string, error) {
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array:
+		data, err := json.Marshal(value)
+		return string(data), err
+	case reflect.Invalid:
+		return "null", nil
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
@@ -16,12 +16,13 @@ var (
 		SilenceUsage: true,
 		RunE:         runRenderCmd,
 	}
-	input  []string
-	output string
-	refs   []string
-	values []string
-	files  []string
-	strict bool
+	input         []string
+	output        string
+	refs          []string
+	values        []string
+	files         []string
+	strict        bool
+	collectErrors bool
 )
 
 func init() {
@@ -32,10 +33,12 @@ func init() {
 	renderCmd.Flags().StringSliceVarP(&files, "files", "f", []string{},
 		"Available files inside templates (directories). It should be noted that all files are immediately loaded into memory. Can be used with '.Files.' prefix.")
 	renderCmd.Flags().BoolVarP(&strict, "strict", "s", false, "In strict mode, rendering is aborted on missing field.")
+	renderCmd.Flags().BoolVar(&collectErrors, "collect-errors", false,
+		"Collects the errors of validation functions of all templates and reports them together. Nothing is written if any validation fails.")
 }
 
 func runRenderCmd(cmd *cobra.Command, args []string) error {
-	renderer := template.NewRenderer().WithConfig(template.Config{Strict: strict})
+	renderer := template.NewRenderer().WithConfig(template.Config{Strict: strict, CollectErrors: collectErrors})
 
 	var sourceStream template.Source
 	if len(input) > 0 {
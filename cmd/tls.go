@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ueisele/go-docker-utils/pkg/template"
+)
+
+var (
+	tlsCmd = &cobra.Command{
+		Use:   "tls files...",
+		Short: "Checks that PEM certificates are valid and do not expire soon.",
+		Long: "Checks that PEM certificates are valid and do not expire soon. Files can be glob patterns and can contain multiple certificates, e.g. a chain, which are all checked. " +
+			"Optionally, it is checked that a private key belongs to the first certificate of every file.",
+		SilenceUsage: true,
+		RunE:         runTlsCmd,
+	}
+	tlsDays int
+	tlsKey  string
+)
+
+func init() {
+	tlsCmd.Flags().IntVarP(&tlsDays, "days", "d", 30, "Fails if a certificate expires within the number of days.")
+	tlsCmd.Flags().StringVarP(&tlsKey, "key", "k", "", "PEM private key, which must belong to the first certificate of every file.")
+	addOutputFlag(tlsCmd)
+}
+
+func runTlsCmd(cmd *cobra.Command, args []string) error {
+	reporter, err := newReporter(cmd)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return reporter.finish(nil, usageError(fmt.Errorf("requires at least one certificate file as argument")))
+	}
+	if tlsDays < 0 {
+		return reporter.finish(nil, usageError(fmt.Errorf("days must not be negative, but was: %d", tlsDays)))
+	}
+	return reporter.finish(checkCertificates(args, tlsKey, tlsDays))
+}
+
+// checkCertificates checks all certificates of the files matching the patterns. The value of a result
+// is the earliest expiry of the certificates of a file.
+func checkCertificates(patterns []string, keyFile string, days int) ([]*checkResult, error) {
+	var key crypto.Signer
+	if keyFile != "" {
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, usageError(fmt.Errorf("could not read key: %v", err))
+		}
+		if key, err = template.ParsePrivateKey(content); err != nil {
+			return nil, usageError(fmt.Errorf("could not parse key: %v", err))
+		}
+	}
+	results := make([]*checkResult, 0, len(patterns))
+	failed := make([]string, 0)
+	for _, pattern := range patterns {
+		filenames, err := template.FileGlobsToFileNames(pattern)
+		if err == nil && len(filenames) == 0 {
+			err = fmt.Errorf("matches no files")
+		}
+		if err != nil {
+			result := startCheck(pattern)
+			result.Attempts = 1
+			results = append(results, result.complete(checkFailedError(err)))
+			failed = append(failed, fmt.Sprintf("%s %v", pattern, err))
+			continue
+		}
+		for _, filename := range filenames {
+			result := startCheck(filename)
+			result.Attempts = 1
+			expiry, err := checkCertificateFile(filename, key, days)
+			if !expiry.IsZero() {
+				result.Value = expiry.UTC().Format(time.RFC3339)
+			}
+			results = append(results, result.complete(err))
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("%s %v", filename, err))
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return results, checkFailedError(fmt.Errorf("certificates are not valid: %s", strings.Join(failed, "; ")))
+	}
+	return results, nil
+}
+
+func checkCertificateFile(filename string, key crypto.Signer, days int) (time.Time, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return time.Time{}, checkFailedError(err)
+	}
+	certs, err := template.ParseCertificates(content)
+	if err != nil {
+		return time.Time{}, checkFailedError(err)
+	}
+	expiry := certs[0].NotAfter
+	for _, cert := range certs[1:] {
+		if cert.NotAfter.Before(expiry) {
+			expiry = cert.NotAfter
+		}
+	}
+	now := time.Now()
+	for _, cert := range certs {
+		subject := cert.Subject.String()
+		switch {
+		case now.Before(cert.NotBefore):
+			return expiry, checkFailedError(fmt.Errorf("%s is not valid before %s", subject, cert.NotBefore.UTC().Format(time.RFC3339)))
+		case now.After(cert.NotAfter):
+			return expiry, checkFailedError(fmt.Errorf("%s expired at %s", subject, cert.NotAfter.UTC().Format(time.RFC3339)))
+		case now.AddDate(0, 0, days).After(cert.NotAfter):
+			return expiry, checkFailedError(fmt.Errorf("%s expires within %d days at %s", subject, days, cert.NotAfter.UTC().Format(time.RFC3339)))
+		}
+	}
+	if key != nil {
+		if !template.KeyMatchesCertificate(key, certs[0]) {
+			return expiry, checkFailedError(fmt.Errorf("key does not belong to %s", certs[0].Subject.String()))
+		}
+	}
+	return expiry, nil
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pathWatcher blocks until something might have changed on the filesystem,
+// which could affect the result of a path check.
+type pathWatcher interface {
+	// wait returns as soon as a change relevant for the pattern was detected,
+	// but at the latest after the poll interval or the given maximum duration.
+	wait(pattern string, maxWait time.Duration)
+	close()
+}
+
+// pollingWatcher does not detect changes and just sleeps for the poll interval.
+type pollingWatcher struct {
+	pollInterval time.Duration
+}
+
+func newPollingWatcher(pollInterval time.Duration) pathWatcher {
+	return &pollingWatcher{pollInterval: pollInterval}
+}
+
+func (w *pollingWatcher) wait(_ string, maxWait time.Duration) {
+	time.Sleep(minDuration(w.pollInterval, maxWait))
+}
+
+func (w *pollingWatcher) close() {}
+
+// watchDirs returns the existing directories in which a change could affect
+// the paths matching the pattern.
+func watchDirs(pattern string) []string {
+	dir := filepath.Dir(pattern)
+	dirs := []string{nearestExistingDir(staticPrefix(dir))}
+	if isPathPattern(dir) {
+		matches, _ := filepath.Glob(dir)
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.IsDir() {
+				dirs = append(dirs, match)
+			}
+		}
+	}
+	return dirs
+}
+
+// staticPrefix strips all trailing path elements which contain glob characters.
+func staticPrefix(path string) string {
+	for isPathPattern(path) {
+		path = filepath.Dir(path)
+	}
+	return path
+}
+
+func nearestExistingDir(path string) string {
+	for {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+func minDuration(a time.Duration, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,96 @@
+//go:build linux
+
+package cmd
+
+import (
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask uint32 = unix.IN_CREATE | unix.IN_MOVED_TO | unix.IN_MOVED_FROM | unix.IN_DELETE |
+	unix.IN_ATTRIB | unix.IN_MODIFY | unix.IN_CLOSE_WRITE | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+
+// inotifyWatcher uses inotify to wake up as soon as a watched directory or one of its
+// entries changes. Changes which are not covered by inotify (e.g. on network filesystems)
+// are still detected after the poll interval.
+type inotifyWatcher struct {
+	fd           int
+	pollInterval time.Duration
+	watches      map[string]int
+	dirs         map[int]string
+	buffer       []byte
+}
+
+// newPathWatcher creates an inotify based watcher and falls back to polling,
+// if inotify is not available.
+func newPathWatcher(pollInterval time.Duration) pathWatcher {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return newPollingWatcher(pollInterval)
+	}
+	return &inotifyWatcher{
+		fd:           fd,
+		pollInterval: pollInterval,
+		watches:      make(map[string]int),
+		dirs:         make(map[int]string),
+		buffer:       make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1)),
+	}
+}
+
+func (w *inotifyWatcher) wait(pattern string, maxWait time.Duration) {
+	for _, dir := range watchDirs(pattern) {
+		w.watch(dir)
+	}
+	waitTime := minDuration(w.pollInterval, maxWait)
+	if waitTime <= 0 {
+		return
+	}
+	fds := []unix.PollFd{{Fd: int32(w.fd), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, int((waitTime+time.Millisecond-1)/time.Millisecond))
+	if err != nil && err != unix.EINTR {
+		time.Sleep(waitTime)
+	} else if n > 0 {
+		w.drain()
+	}
+}
+
+func (w *inotifyWatcher) watch(dir string) {
+	if _, ok := w.watches[dir]; ok {
+		return
+	}
+	wd, err := unix.InotifyAddWatch(w.fd, dir, inotifyMask)
+	if err != nil {
+		// the poll interval still applies
+		return
+	}
+	w.watches[dir] = wd
+	w.dirs[wd] = dir
+}
+
+// drain reads all pending events, so that the next wait blocks again. Watches which
+// were removed by the kernel (e.g. because the directory was deleted) are forgotten,
+// so that they are added again if the directory is re-created.
+func (w *inotifyWatcher) drain() {
+	for {
+		n, err := unix.Read(w.fd, w.buffer)
+		if err != nil || n < unix.SizeofInotifyEvent {
+			return
+		}
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&w.buffer[offset]))
+			if event.Mask&unix.IN_IGNORED != 0 {
+				if dir, ok := w.dirs[int(event.Wd)]; ok {
+					delete(w.watches, dir)
+					delete(w.dirs, int(event.Wd))
+				}
+			}
+			offset += unix.SizeofInotifyEvent + int(event.Len)
+		}
+	}
+}
+
+func (w *inotifyWatcher) close() {
+	unix.Close(w.fd)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package cmd
+
+import (
+	"time"
+)
+
+func newPathWatcher(pollInterval time.Duration) pathWatcher {
+	return newPollingWatcher(pollInterval)
+}
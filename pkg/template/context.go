@@ -22,7 +22,7 @@ func (cb *ContextBuilder) initTypeDecoderRegistry() {
 	cb.typeDecoderRegistry[".yml"] = fromYAML
 	cb.typeDecoderRegistry[".yaml"] = fromYAML
 	cb.typeDecoderRegistry[".toml"] = fromTOML
-	cb.typeDecoderRegistry[".properties"] = func(text string) (interface{}, error) { return fromProperties(text) }
+	cb.typeDecoderRegistry[".properties"] = func(text string) (interface{}, error) { return fromPropertiesToMap(text) }
 }
 
 func (cb *ContextBuilder) Build() (context map[string]interface{}, err error) {
@@ -78,7 +78,7 @@ func (cb *ContextBuilder) WithToml(text, scope string) error {
 }
 
 func (cb *ContextBuilder) WithProperties(text, scope string) error {
-	out, err := fromProperties(text)
+	out, err := fromPropertiesToMap(text)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,254 @@
+package template
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultDnsTimeout = 5 * time.Second
+
+// SRVRecord is a DNS SRV record. In templates it is rendered as host:port.
+type SRVRecord struct {
+	Target   string
+	Port     uint16
+	Priority uint16
+	Weight   uint16
+}
+
+func (r SRVRecord) String() string {
+	return net.JoinHostPort(r.Target, strconv.Itoa(int(r.Port)))
+}
+
+// dnsOptions are the optional settings of DNS functions, passed as dict, e.g.
+//
+//	{{ lookupHost "kafka" (dict "timeout" "2s" "default" (list "127.0.0.1")) }}
+//
+// If a default is defined, it is returned if the lookup fails, instead of an error. A nil default
+// is an empty list. With resolver, the DNS server with this address (host[:port]) is used instead
+// of the system resolver, e.g. a local stub server.
+type dnsOptions struct {
+	timeout     time.Duration
+	fallback    interface{}
+	hasFallback bool
+	resolver    *net.Resolver
+}
+
+func parseDnsOptions(options []interface{}) (dnsOptions, error) {
+	parsed := dnsOptions{timeout: defaultDnsTimeout, resolver: net.DefaultResolver}
+	if len(options) == 0 {
+		return parsed, nil
+	}
+	if len(options) > 1 {
+		return parsed, fmt.Errorf("expected at most one options dict, but got %d arguments", len(options))
+	}
+	optionsVal := reflect.ValueOf(options[0])
+	if optionsVal.Kind() != reflect.Map {
+		return parsed, fmt.Errorf("options must be a dict but was %T", options[0])
+	}
+	iter := optionsVal.MapRange()
+	for iter.Next() {
+		key := strval(iter.Key().Interface())
+		value := iter.Value().Interface()
+		switch key {
+		case "timeout":
+			timeout, err := toDuration(value)
+			if err != nil {
+				return parsed, fmt.Errorf("invalid timeout: %v", err)
+			}
+			parsed.timeout = timeout
+		case "default":
+			parsed.fallback, parsed.hasFallback = value, true
+		case "resolver":
+			parsed.resolver = newDnsResolver(strval(value))
+		default:
+			return parsed, fmt.Errorf("unknown option %s, supported are [timeout, default, resolver]", key)
+		}
+	}
+	return parsed, nil
+}
+
+// newDnsResolver returns a resolver which sends all queries to the DNS server with the address
+// host[:port]. The port defaults to 53.
+func newDnsResolver(address string) *net.Resolver {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+}
+
+func (o dnsOptions) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), o.timeout)
+}
+
+func toDuration(v interface{}) (time.Duration, error) {
+	switch t := v.(type) {
+	case time.Duration:
+		return t, nil
+	case int:
+		return time.Duration(t) * time.Second, nil
+	case int64:
+		return time.Duration(t) * time.Second, nil
+	case float64:
+		return time.Duration(t * float64(time.Second)), nil
+	default:
+		return time.ParseDuration(strval(v))
+	}
+}
+
+// lookupHost returns the addresses of a host.
+//
+//	bootstrap.servers={{ range $i, $ip := lookupHost "kafka-headless" }}{{ if $i }},{{ end }}{{ $ip }}:9092{{ end }}
+func lookupHost(host string, options ...interface{}) ([]string, error) {
+	opts, err := parseDnsOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := opts.context()
+	defer cancel()
+	addrs, err := opts.resolver.LookupHost(ctx, host)
+	if err != nil {
+		if opts.hasFallback {
+			return toFlatListOfStrings(opts.fallback), nil
+		}
+		return nil, err
+	}
+	return addrs, nil
+}
+
+// lookupSRV returns the SRV records of a name (e.g. _kafka._tcp.kafka.default.svc.cluster.local),
+// sorted by priority and randomized by weight. A default is a list of host:port strings.
+func lookupSRV(name string, options ...interface{}) ([]SRVRecord, error) {
+	opts, err := parseDnsOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := opts.context()
+	defer cancel()
+	_, srvs, err := opts.resolver.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		if opts.hasFallback {
+			return hostPortsToSRVRecords(toFlatListOfStrings(opts.fallback))
+		}
+		return nil, err
+	}
+	records := make([]SRVRecord, 0, len(srvs))
+	for _, srv := range srvs {
+		records = append(records, SRVRecord{Target: strings.TrimSuffix(srv.Target, "."), Port: srv.Port, Priority: srv.Priority, Weight: srv.Weight})
+	}
+	return records, nil
+}
+
+func hostPortsToSRVRecords(hostPorts []string) ([]SRVRecord, error) {
+	records := make([]SRVRecord, 0, len(hostPorts))
+	for _, hostPort := range hostPorts {
+		host, portText, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			return nil, fmt.Errorf("default must be a list of host:port, but contained %s", hostPort)
+		}
+		port, err := strconv.ParseUint(portText, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("default must be a list of host:port, but contained %s", hostPort)
+		}
+		records = append(records, SRVRecord{Target: host, Port: uint16(port)})
+	}
+	return records, nil
+}
+
+// lookupAddr returns the names of an address (reverse lookup), without trailing dot.
+func lookupAddr(addr string, options ...interface{}) ([]string, error) {
+	opts, err := parseDnsOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := opts.context()
+	defer cancel()
+	names, err := opts.resolver.LookupAddr(ctx, addr)
+	if err != nil {
+		if opts.hasFallback {
+			return toFlatListOfStrings(opts.fallback), nil
+		}
+		return nil, err
+	}
+	for i := range names {
+		names[i] = strings.TrimSuffix(names[i], ".")
+	}
+	return names, nil
+}
+
+// lookupCNAME returns the canonical name of a host, without trailing dot.
+func lookupCNAME(host string, options ...interface{}) (string, error) {
+	opts, err := parseDnsOptions(options)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := opts.context()
+	defer cancel()
+	cname, err := opts.resolver.LookupCNAME(ctx, host)
+	if err != nil {
+		if opts.hasFallback {
+			return strval(opts.fallback), nil
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(cname, "."), nil
+}
+
+// hostname returns the host name reported by the kernel.
+func hostname() (string, error) {
+	return os.Hostname()
+}
+
+// fqdn returns the fully qualified domain name of this host. Like Python's socket.getfqdn,
+// the first name containing a dot is returned, which is either the hostname itself, its
+// canonical name or a name of one of its addresses. If there is none, the default or, if not
+// defined, the hostname is returned.
+func fqdn(options ...interface{}) (string, error) {
+	opts, err := parseDnsOptions(options)
+	if err != nil {
+		return "", err
+	}
+	name, err := os.Hostname()
+	if err != nil {
+		if opts.hasFallback {
+			return strval(opts.fallback), nil
+		}
+		return "", err
+	}
+	if strings.Contains(name, ".") {
+		return name, nil
+	}
+	ctx, cancel := opts.context()
+	defer cancel()
+	if cname, err := opts.resolver.LookupCNAME(ctx, name); err == nil && strings.Contains(strings.TrimSuffix(cname, "."), ".") {
+		return strings.TrimSuffix(cname, "."), nil
+	}
+	if addrs, err := opts.resolver.LookupHost(ctx, name); err == nil {
+		for _, addr := range addrs {
+			names, err := opts.resolver.LookupAddr(ctx, addr)
+			if err != nil {
+				continue
+			}
+			for _, candidate := range names {
+				if candidate = strings.TrimSuffix(candidate, "."); strings.Contains(candidate, ".") {
+					return candidate, nil
+				}
+			}
+		}
+	}
+	if opts.hasFallback {
+		return strval(opts.fallback), nil
+	}
+	return name, nil
+}
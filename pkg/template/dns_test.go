@@ -0,0 +1,177 @@
+package template
+
+import (
+	"encoding/binary"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	dnsTypeA   uint16 = 1
+	dnsTypeSRV uint16 = 33
+)
+
+// startStubDnsServer starts a UDP DNS server on localhost, which answers A and SRV queries for the
+// names of the records and responds with NXDOMAIN for all other names. It returns its address.
+func startStubDnsServer(t *testing.T, aRecords map[string]net.IP, srvRecords map[string][]SRVRecord) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if response := stubDnsResponse(buf[:n], aRecords, srvRecords); response != nil {
+				_, _ = conn.WriteTo(response, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func stubDnsResponse(query []byte, aRecords map[string]net.IP, srvRecords map[string][]SRVRecord) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+	labels := make([]string, 0)
+	offset := 12
+	for offset < len(query) && query[offset] != 0 {
+		length := int(query[offset])
+		if offset+1+length > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[offset+1:offset+1+length]))
+		offset += 1 + length
+	}
+	offset += 5
+	if offset > len(query) {
+		return nil
+	}
+	name := strings.ToLower(strings.Join(labels, "."))
+	qtype := binary.BigEndian.Uint16(query[offset-4:])
+
+	answers := make([][]byte, 0)
+	_, knownA := aRecords[name]
+	_, knownSRV := srvRecords[name]
+	switch {
+	case qtype == dnsTypeA && knownA:
+		answers = append(answers, aRecords[name].To4())
+	case qtype == dnsTypeSRV && knownSRV:
+		for _, srv := range srvRecords[name] {
+			data := binary.BigEndian.AppendUint16(nil, srv.Priority)
+			data = binary.BigEndian.AppendUint16(data, srv.Weight)
+			data = binary.BigEndian.AppendUint16(data, srv.Port)
+			for _, label := range strings.Split(srv.Target, ".") {
+				data = append(append(data, byte(len(label))), label...)
+			}
+			answers = append(answers, append(data, 0))
+		}
+	}
+	flags := uint16(0x8180)
+	if !knownA && !knownSRV {
+		flags |= 3 // NXDOMAIN
+	}
+	response := append([]byte{}, query[:2]...)
+	response = binary.BigEndian.AppendUint16(response, flags)
+	response = binary.BigEndian.AppendUint16(response, 1)
+	response = binary.BigEndian.AppendUint16(response, uint16(len(answers)))
+	response = append(response, 0, 0, 0, 0)
+	response = append(response, query[12:offset]...)
+	for _, data := range answers {
+		response = append(response, 0xC0, 12)
+		response = binary.BigEndian.AppendUint16(response, qtype)
+		response = binary.BigEndian.AppendUint16(response, 1)
+		response = binary.BigEndian.AppendUint32(response, 60)
+		response = binary.BigEndian.AppendUint16(response, uint16(len(data)))
+		response = append(response, data...)
+	}
+	return response
+}
+
+func TestLookupWithResolver(t *testing.T) {
+	resolver := startStubDnsServer(t,
+		map[string]net.IP{"kafka.test": net.ParseIP("10.1.2.3")},
+		map[string][]SRVRecord{"_kafka._tcp.kafka.test": {{Target: "broker-0.kafka.test", Port: 9092}}},
+	)
+
+	addrs, err := lookupHost("kafka.test", map[string]interface{}{"resolver": resolver, "timeout": "2s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(addrs, []string{"10.1.2.3"}) {
+		t.Errorf("expected [10.1.2.3], but got %v", addrs)
+	}
+
+	records, err := lookupSRV("_kafka._tcp.kafka.test", map[string]interface{}{"resolver": resolver, "timeout": "2s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].String() != "broker-0.kafka.test:9092" {
+		t.Errorf("expected [broker-0.kafka.test:9092], but got %v", records)
+	}
+
+	if _, err := lookupHost("unknown.test", map[string]interface{}{"resolver": resolver, "timeout": "2s"}); err == nil {
+		t.Error("expected an error for an unknown host")
+	}
+}
+
+func TestLookupFallback(t *testing.T) {
+	resolver := startStubDnsServer(t, map[string]net.IP{}, map[string][]SRVRecord{})
+	tests := []struct {
+		name     string
+		fallback interface{}
+		expected []string
+	}{
+		{"nil", nil, []string{}},
+		{"list", []interface{}{"127.0.0.1", "::1"}, []string{"127.0.0.1", "::1"}},
+		{"string", "127.0.0.1", []string{"127.0.0.1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := map[string]interface{}{"resolver": resolver, "timeout": "2s", "default": test.fallback}
+			addrs, err := lookupHost("nonexistent.invalid", options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(addrs, test.expected) {
+				t.Errorf("expected %v, but got %v", test.expected, addrs)
+			}
+		})
+	}
+}
+
+func TestLookupSRVFallback(t *testing.T) {
+	resolver := startStubDnsServer(t, map[string]net.IP{}, map[string][]SRVRecord{})
+	records, err := lookupSRV("_kafka._tcp.nonexistent.invalid", map[string]interface{}{"resolver": resolver, "default": nil})
+	if err != nil || len(records) != 0 {
+		t.Errorf("expected no records, but got %v (%v)", records, err)
+	}
+	records, err = lookupSRV("_kafka._tcp.nonexistent.invalid", map[string]interface{}{"resolver": resolver, "default": []string{"localhost:9092"}})
+	if err != nil || len(records) != 1 || records[0].String() != "localhost:9092" {
+		t.Errorf("expected [localhost:9092], but got %v (%v)", records, err)
+	}
+	if _, err := lookupSRV("_kafka._tcp.nonexistent.invalid", map[string]interface{}{"resolver": resolver, "default": "localhost"}); err == nil {
+		t.Error("expected an error for a default without port")
+	}
+}
+
+func TestParseDnsOptionsErrors(t *testing.T) {
+	tests := []interface{}{
+		"2s",
+		map[string]interface{}{"timeout": "two seconds"},
+		map[string]interface{}{"server": "127.0.0.1"},
+	}
+	for _, options := range tests {
+		if _, err := parseDnsOptions([]interface{}{options}); err == nil {
+			t.Errorf("expected an error for %v", options)
+		}
+	}
+}
@@ -0,0 +1,1024 @@
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/magiconair/properties"
+	"go.yaml.in/yaml/v3"
+)
+
+// Formats of documents.
+const (
+	FormatYAML       string = "yaml"
+	FormatJSON       string = "json"
+	FormatTOML       string = "toml"
+	FormatProperties string = "properties"
+)
+
+var documentFormats = []string{FormatYAML, FormatJSON, FormatTOML, FormatProperties}
+
+// FormatOf returns the format of a file based on its extension.
+func FormatOf(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".json":
+		return FormatJSON, nil
+	case ".toml":
+		return FormatTOML, nil
+	case ".properties":
+		return FormatProperties, nil
+	default:
+		return "", fmt.Errorf("format of %s cannot be determined by its extension, supported are %v", filename, documentFormats)
+	}
+}
+
+// CheckFormat returns an error, if the format is not one of the supported formats.
+func CheckFormat(format string) error {
+	for _, supported := range documentFormats {
+		if format == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("format must be one of %v, but was: %s", documentFormats, format)
+}
+
+// Decode parses a text of the given format into maps, lists and scalars.
+func Decode(format string, text string) (interface{}, error) {
+	switch format {
+	case FormatYAML:
+		return fromYAML(text)
+	case FormatJSON:
+		return fromJSON(text)
+	case FormatTOML:
+		return fromTOML(text)
+	case FormatProperties:
+		return fromPropertiesToMap(text)
+	default:
+		return nil, fmt.Errorf("format must be one of %v, but was: %s", documentFormats, format)
+	}
+}
+
+// Encode writes a value in the given format. JSON is indented with two spaces.
+func Encode(format string, value interface{}) (string, error) {
+	switch format {
+	case FormatYAML:
+		return toYAML(value)
+	case FormatJSON:
+		return toJSONPretty("  ", value)
+	case FormatTOML:
+		return toTOML(value)
+	case FormatProperties:
+		return toProperties(value)
+	default:
+		return "", fmt.Errorf("format must be one of %v, but was: %s", documentFormats, format)
+	}
+}
+
+// Document is a config file, which can be modified and written back in its format.
+//
+// YAML and JSON documents are kept as yaml.v3 nodes, so that comments (YAML only) and the
+// order of keys are preserved. Entries of a YAML map and lines of properties which are not
+// modified are written exactly as they were read. TOML documents are written with sorted keys
+// and without comments, because the TOML library does not support anything else.
+type Document struct {
+	format     string
+	root       *yaml.Node
+	props      *properties.Properties
+	indent     string
+	compactSeq bool
+	head       string                 // yaml: the text before the first entry of the map
+	sections   map[string]yamlSection // yaml: the entries of the map as they were read
+	lines      []propertiesLine       // properties: the lines as they were read
+}
+
+// yamlSection is an entry of the map of a yaml document as it was read.
+type yamlSection struct {
+	text       string // the entry with its comments
+	trailer    string // the blank lines after the entry
+	encoded    string // the entry as written by the encoder, to detect changes
+	compactSeq bool
+}
+
+// propertiesLine is a logical line of properties as it was read, which is either an entry
+// (including continuation lines), a comment or a blank line.
+type propertiesLine struct {
+	text  string
+	entry bool
+	key   string
+	value string
+}
+
+// LoadDocument parses the content of a config file of the given format.
+func LoadDocument(format string, content []byte) (*Document, error) {
+	doc := &Document{format: format, indent: detectIndent(content)}
+	switch format {
+	case FormatYAML, FormatJSON:
+		var node yaml.Node
+		if err := yaml.Unmarshal(content, &node); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %v", format, err)
+		}
+		doc.root = documentRoot(&node)
+		if format == FormatYAML {
+			if err := doc.loadYAMLSections(content); err != nil {
+				return nil, err
+			}
+		}
+	case FormatTOML:
+		var out map[string]interface{}
+		if _, err := toml.Decode(string(content), &out); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %v", format, err)
+		}
+		doc.root = &yaml.Node{}
+		if err := doc.root.Encode(out); err != nil {
+			return nil, err
+		}
+	case FormatProperties:
+		loader := properties.Loader{Encoding: properties.UTF8, DisableExpansion: true}
+		props, err := loader.LoadBytes(content)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s: %v", format, err)
+		}
+		props.WriteSeparator = detectSeparator(content)
+		doc.props = props
+		if doc.lines, err = parsePropertiesLines(content); err != nil {
+			return nil, fmt.Errorf("could not parse %s: %v", format, err)
+		}
+	default:
+		return nil, fmt.Errorf("format must be one of %v, but was: %s", documentFormats, format)
+	}
+	return doc, nil
+}
+
+func documentRoot(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		return node.Content[0]
+	}
+	if node.Kind == 0 || node.Kind == yaml.DocumentNode {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	return node
+}
+
+var leadingIndentPattern = regexp.MustCompile(`(?m)^([ \t]+)\S`)
+
+// detectIndent returns the smallest indentation of the content, which is used when writing JSON back.
+// If lines are indented with tabs, the indentation is a tab.
+func detectIndent(content []byte) string {
+	indent := ""
+	for _, match := range leadingIndentPattern.FindAllSubmatch(content, -1) {
+		if match[1][0] == '\t' {
+			return "\t"
+		}
+		if indent == "" || len(match[1]) < len(indent) {
+			indent = string(match[1])
+		}
+	}
+	return indent
+}
+
+// yamlIndent returns the indentation of the first map in a map, or else of the first indented
+// list in a map, based on the columns of the nodes. It is 0 if there is none.
+func yamlIndent(node *yaml.Node) int {
+	listIndent := 0
+	var walk func(node *yaml.Node) int
+	walk = func(node *yaml.Node) int {
+		for i := 0; i+1 < len(node.Content); i++ {
+			if node.Kind != yaml.MappingNode {
+				if indent := walk(node.Content[i]); indent > 0 {
+					return indent
+				}
+				continue
+			}
+			key, value := node.Content[i], node.Content[i+1]
+			i++
+			if value.Style&yaml.FlowStyle != 0 || value.Line == key.Line {
+				continue
+			}
+			switch {
+			case value.Kind == yaml.MappingNode && len(value.Content) > 0:
+				return value.Content[0].Column - key.Column
+			case value.Kind == yaml.SequenceNode && listIndent == 0 && value.Column > key.Column:
+				listIndent = value.Column - key.Column
+			}
+			if indent := walk(value); indent > 0 {
+				return indent
+			}
+		}
+		if node.Kind == yaml.SequenceNode && len(node.Content) > 0 {
+			return walk(node.Content[len(node.Content)-1])
+		}
+		return 0
+	}
+	if indent := walk(node); indent > 0 {
+		return indent
+	}
+	return listIndent
+}
+
+// yamlCompactSequences returns true, if the first list in a map is not indented, e.g.
+//
+//	hosts:
+//	- kafka-1
+//
+// If there is no such list, found is false.
+func yamlCompactSequences(node *yaml.Node) (compact bool, found bool) {
+	for i, child := range node.Content {
+		if node.Kind == yaml.MappingNode && i%2 == 1 && child.Kind == yaml.SequenceNode &&
+			child.Style&yaml.FlowStyle == 0 && len(child.Content) > 0 {
+			return child.Column == node.Content[i-1].Column, true
+		}
+		if compact, found := yamlCompactSequences(child); found {
+			return compact, true
+		}
+	}
+	return false, false
+}
+
+// loadYAMLSections splits the content into the entries of the map, so that entries which are not modified
+// are written as they were read. Comments directly above a key belong to its entry, blank lines and other
+// comments belong to the entry before.
+func (d *Document) loadYAMLSections(content []byte) error {
+	indent := yamlIndent(d.root)
+	if indent < 2 {
+		indent = 2
+	}
+	d.indent = strings.Repeat(" ", indent)
+	d.compactSeq, _ = yamlCompactSequences(d.root)
+	if d.root.Kind != yaml.MappingNode || d.root.Style&yaml.FlowStyle != 0 {
+		return nil
+	}
+	if len(d.root.Content) == 0 {
+		d.head, d.sections = string(content), map[string]yamlSection{}
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	starts := make([]int, 0, len(d.root.Content)/2)
+	for i := 0; i < len(d.root.Content); i += 2 {
+		key := d.root.Content[i]
+		if key.Column != 1 || key.Line < 1 || key.Line > len(lines) {
+			return nil
+		}
+		start := key.Line - 1
+		for start > 0 && strings.HasPrefix(lines[start-1], "#") && (len(starts) == 0 || start-1 > starts[len(starts)-1]) {
+			start--
+		}
+		if len(starts) > 0 && start <= starts[len(starts)-1] {
+			return nil
+		}
+		starts = append(starts, start)
+	}
+	d.head = strings.Join(lines[:starts[0]], "")
+	d.sections = make(map[string]yamlSection, len(starts))
+	for i, start := range starts {
+		end := len(lines)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		blank := end
+		for blank > start+1 && strings.TrimSpace(lines[blank-1]) == "" {
+			blank--
+		}
+		key, value := d.root.Content[2*i], d.root.Content[2*i+1]
+		section := yamlSection{
+			text:       strings.Join(lines[start:blank], ""),
+			trailer:    strings.Join(lines[blank:end], ""),
+			compactSeq: d.compactSeq,
+		}
+		if compact, found := yamlCompactSequences(&yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{key, value}}); found {
+			section.compactSeq = compact
+		}
+		encoded, err := encodeYAMLNode(yamlEntry(key, value), indent, section.compactSeq)
+		if err != nil {
+			return err
+		}
+		section.encoded = string(encoded)
+		d.sections[key.Value] = section
+	}
+	return nil
+}
+
+func yamlEntry(key *yaml.Node, value *yaml.Node) *yaml.Node {
+	return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{key, value}}
+}
+
+// encodeYAMLNode writes a node as yaml. Lists in maps are not indented with compactSeq,
+// or rather '- ' is part of the indentation, if the indentation is larger than two.
+func encodeYAMLNode(node *yaml.Node, indent int, compactSeq bool) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(indent)
+	if compactSeq {
+		encoder.CompactSeqIndent()
+	}
+	if err := encoder.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeYAML writes the entries of the map which are not modified as they were read, and encodes the others.
+func (d *Document) encodeYAML() ([]byte, error) {
+	indent := len(d.indent)
+	if indent < 2 {
+		indent = 2
+	}
+	if d.sections == nil || d.root.Kind != yaml.MappingNode || (len(d.root.Content) == 0 && len(d.sections) > 0) {
+		return encodeYAMLNode(d.root, indent, d.compactSeq)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(d.head)
+	for i := 0; i+1 < len(d.root.Content); i += 2 {
+		key, value := d.root.Content[i], d.root.Content[i+1]
+		section, found := d.sections[key.Value]
+		if !found {
+			section.compactSeq = d.compactSeq
+		}
+		encoded, err := encodeYAMLNode(yamlEntry(key, value), indent, section.compactSeq)
+		if err != nil {
+			return nil, err
+		}
+		if found && string(encoded) == section.encoded {
+			buf.WriteString(section.text)
+		} else {
+			if buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '\n' {
+				buf.WriteByte('\n')
+			}
+			buf.Write(encoded)
+		}
+		buf.WriteString(section.trailer)
+	}
+	return buf.Bytes(), nil
+}
+
+// parsePropertiesLines splits properties into logical lines and decodes the key and value of each entry.
+func parsePropertiesLines(content []byte) ([]propertiesLine, error) {
+	loader := properties.Loader{Encoding: properties.UTF8, DisableExpansion: true}
+	physical := strings.SplitAfter(string(content), "\n")
+	lines := make([]propertiesLine, 0, len(physical))
+	for i := 0; i < len(physical); i++ {
+		text := physical[i]
+		trimmed := strings.TrimLeft(text, " \t\f")
+		if text == "" {
+			continue
+		}
+		if strings.TrimSpace(trimmed) == "" || trimmed[0] == '#' || trimmed[0] == '!' {
+			lines = append(lines, propertiesLine{text: text})
+			continue
+		}
+		for continuesLine(text) && i+1 < len(physical) {
+			i++
+			text += physical[i]
+		}
+		entry, err := loader.LoadBytes([]byte(text))
+		if err != nil {
+			return nil, err
+		}
+		line := propertiesLine{text: text}
+		if keys := entry.Keys(); len(keys) == 1 {
+			line.entry, line.key = true, keys[0]
+			line.value, _ = entry.Get(line.key)
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// continuesLine returns true, if a line of properties ends with an odd number of backslashes.
+func continuesLine(text string) bool {
+	text = strings.TrimRight(text, "\r\n")
+	return (len(text)-len(strings.TrimRight(text, "\\")))%2 == 1
+}
+
+var propertiesSeparatorPattern = regexp.MustCompile(`(?m)^[ \t\f]*(?:[^#!\s:=\\]|\\.)(?:[^\s:=\\]|\\.)*([ \t\f]*[:=][ \t\f]*|[ \t\f]+)\S`)
+
+// detectSeparator returns the separator of key and value of the first property, or " = " if there is none.
+func detectSeparator(content []byte) string {
+	if match := propertiesSeparatorPattern.FindSubmatch(content); match != nil {
+		return string(match[1])
+	}
+	return " = "
+}
+
+// Encode writes the document in its format.
+func (d *Document) Encode() ([]byte, error) {
+	switch d.format {
+	case FormatYAML:
+		return d.encodeYAML()
+	case FormatJSON:
+		var buf bytes.Buffer
+		if err := writeNodeAsJSON(&buf, d.root); err != nil {
+			return nil, err
+		}
+		if d.indent == "" {
+			return append(buf.Bytes(), '\n'), nil
+		}
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, buf.Bytes(), "", d.indent); err != nil {
+			return nil, err
+		}
+		return append(indented.Bytes(), '\n'), nil
+	case FormatTOML:
+		var out map[string]interface{}
+		if err := d.root.Decode(&out); err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(out); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return d.encodeProperties()
+	}
+}
+
+// encodeProperties writes the lines which are not modified as they were read, and rewrites only
+// modified entries. Deleted entries are removed and new entries are appended.
+func (d *Document) encodeProperties() ([]byte, error) {
+	var buf bytes.Buffer
+	written := make(map[string]bool)
+	for _, line := range d.lines {
+		if !line.entry {
+			buf.WriteString(line.text)
+			continue
+		}
+		written[line.key] = true
+		value, exists := d.props.Get(line.key)
+		switch {
+		case !exists:
+		case value == line.value:
+			buf.WriteString(line.text)
+		default:
+			entry, err := d.propertiesEntry(line.key, value)
+			if err != nil {
+				return nil, err
+			}
+			if strings.HasSuffix(line.text, "\r\n") {
+				entry = strings.TrimSuffix(entry, "\n") + "\r\n"
+			}
+			buf.WriteString(entry)
+		}
+	}
+	for _, key := range d.props.Keys() {
+		if written[key] {
+			continue
+		}
+		value, _ := d.props.Get(key)
+		entry, err := d.propertiesEntry(key, value)
+		if err != nil {
+			return nil, err
+		}
+		if buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(entry)
+	}
+	return buf.Bytes(), nil
+}
+
+// propertiesEntry writes a single entry with the separator of the document.
+func (d *Document) propertiesEntry(key string, value string) (string, error) {
+	entry := properties.NewProperties()
+	entry.DisableExpansion = true
+	entry.WriteSeparator = d.props.WriteSeparator
+	if _, _, err := entry.Set(key, value); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if _, err := entry.Write(&buf, properties.UTF8); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Value returns the content of the document as maps, lists and scalars.
+func (d *Document) Value() (interface{}, error) {
+	if d.props != nil {
+		m := make(map[string]interface{})
+		for _, key := range d.props.Keys() {
+			m[key], _ = d.props.Get(key)
+		}
+		return m, nil
+	}
+	var out interface{}
+	if err := d.root.Decode(&out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ParsePath splits a path into its segments. A path is either a JSON pointer (e.g. /a/b/0)
+// or dotted (e.g. a.b.0). In dotted paths, dots which are part of a key are escaped with
+// a backslash (e.g. log4j\.rootLogger).
+func ParsePath(path string) []string {
+	if path == "" {
+		return []string{}
+	}
+	if strings.HasPrefix(path, "/") {
+		segments := strings.Split(path[1:], "/")
+		for i, segment := range segments {
+			segments[i] = strings.ReplaceAll(strings.ReplaceAll(segment, "~1", "/"), "~0", "~")
+		}
+		return segments
+	}
+	segments := make([]string, 0)
+	var segment strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path) && path[i+1] == '.':
+			segment.WriteByte('.')
+			i++
+		case path[i] == '.':
+			segments = append(segments, segment.String())
+			segment.Reset()
+		default:
+			segment.WriteByte(path[i])
+		}
+	}
+	return append(segments, segment.String())
+}
+
+// propertiesKey returns the key of a property for a path. Segments are joined by dots, so that
+// both a.b and /a.b address the property a.b.
+func propertiesKey(segments []string) (string, error) {
+	if len(segments) == 0 {
+		return "", fmt.Errorf("path must not be empty")
+	}
+	return strings.Join(segments, "."), nil
+}
+
+// ParseValue parses a value as YAML, so that e.g. 1 is a number, true a bool and [a, b] a list.
+// An empty text is an empty string.
+func ParseValue(text string) (interface{}, error) {
+	if text == "" {
+		return "", nil
+	}
+	var out interface{}
+	if err := yaml.Unmarshal([]byte(text), &out); err != nil {
+		return nil, fmt.Errorf("could not parse value %s: %v", text, err)
+	}
+	return out, nil
+}
+
+// Get returns the value at the path.
+func (d *Document) Get(path string) (interface{}, bool, error) {
+	segments := ParsePath(path)
+	if d.props != nil {
+		key, err := propertiesKey(segments)
+		if err != nil {
+			return nil, false, err
+		}
+		value, ok := d.props.Get(key)
+		return value, ok, nil
+	}
+	node, err := resolveNode(d.root, segments, false)
+	if err != nil || node == nil {
+		return nil, false, nil
+	}
+	var out interface{}
+	if err := node.Decode(&out); err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// Set sets the value at the path. Missing maps are created. The last segment of a path to a
+// list is an index or '-', which appends the value.
+func (d *Document) Set(path string, value interface{}) error {
+	return d.set(ParsePath(path), value, false)
+}
+
+func (d *Document) set(segments []string, value interface{}, insert bool) error {
+	if d.props != nil {
+		key, err := propertiesKey(segments)
+		if err != nil {
+			return err
+		}
+		stringValue, err := toPropertiesString(value)
+		if err != nil {
+			return err
+		}
+		_, _, err = d.props.Set(key, stringValue)
+		return err
+	}
+	valueNode, err := toNode(value)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		*d.root = *valueNode
+		return nil
+	}
+	parent, err := resolveNode(d.root, segments[:len(segments)-1], true)
+	if err != nil {
+		return err
+	}
+	return setChild(parent, segments, valueNode, insert)
+}
+
+// Delete removes the value at the path. It returns false if there was no value.
+func (d *Document) Delete(path string) (bool, error) {
+	segments := ParsePath(path)
+	if d.props != nil {
+		key, err := propertiesKey(segments)
+		if err != nil {
+			return false, err
+		}
+		_, ok := d.props.Get(key)
+		d.props.Delete(key)
+		return ok, nil
+	}
+	if len(segments) == 0 {
+		return false, fmt.Errorf("path must not be empty")
+	}
+	parent, err := resolveNode(d.root, segments[:len(segments)-1], false)
+	if err != nil || parent == nil {
+		return false, nil
+	}
+	last := segments[len(segments)-1]
+	switch parent.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(parent.Content); i += 2 {
+			if parent.Content[i].Value == last {
+				parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+				return true, nil
+			}
+		}
+	case yaml.SequenceNode:
+		index, err := strconv.Atoi(last)
+		if err == nil && index >= 0 && index < len(parent.Content) {
+			parent.Content = append(parent.Content[:index], parent.Content[index+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// MergePatch applies a JSON merge patch (RFC 7396). Null values delete keys.
+func (d *Document) MergePatch(patch interface{}) error {
+	if d.props != nil {
+		return d.mergePatchProperties("", patch)
+	}
+	merged, err := mergePatchNode(d.root, patch)
+	if err != nil {
+		return err
+	}
+	*d.root = *merged
+	return nil
+}
+
+func (d *Document) mergePatchProperties(prefix string, patch interface{}) error {
+	patchVal := reflect.ValueOf(patch)
+	if patchVal.Kind() != reflect.Map {
+		return fmt.Errorf("merge patch of properties must be a map but was %T", patch)
+	}
+	iter := patchVal.MapRange()
+	for iter.Next() {
+		key := prefix + strval(iter.Key().Interface())
+		value := iter.Value().Interface()
+		switch {
+		case value == nil:
+			d.props.Delete(key)
+		case reflect.ValueOf(value).Kind() == reflect.Map:
+			if err := d.mergePatchProperties(key+".", value); err != nil {
+				return err
+			}
+		default:
+			if err := d.set([]string{key}, value, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func mergePatchNode(target *yaml.Node, patch interface{}) (*yaml.Node, error) {
+	patchVal := reflect.ValueOf(patch)
+	if patchVal.Kind() != reflect.Map {
+		return toNode(patch)
+	}
+	if target == nil || target.Kind != yaml.MappingNode {
+		target = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	iter := patchVal.MapRange()
+	for iter.Next() {
+		key := strval(iter.Key().Interface())
+		value := iter.Value().Interface()
+		index := mappingIndex(target, key)
+		if value == nil {
+			if index >= 0 {
+				target.Content = append(target.Content[:index], target.Content[index+2:]...)
+			}
+			continue
+		}
+		var current *yaml.Node
+		if index >= 0 {
+			current = target.Content[index+1]
+		}
+		merged, err := mergePatchNode(current, value)
+		if err != nil {
+			return nil, err
+		}
+		if index >= 0 {
+			target.Content[index+1] = keepComments(current, merged)
+		} else {
+			target.Content = append(target.Content, stringNode(key), merged)
+		}
+	}
+	return target, nil
+}
+
+// JSONPatch applies a JSON patch (RFC 6902), which is a list of operations
+// (add, remove, replace, move, copy, test) with JSON pointers as paths.
+func (d *Document) JSONPatch(patch interface{}) error {
+	operations := reflect.ValueOf(patch)
+	if operations.Kind() != reflect.Slice {
+		return fmt.Errorf("JSON patch must be a list of operations but was %T", patch)
+	}
+	for i := 0; i < operations.Len(); i++ {
+		operation, ok := operations.Index(i).Interface().(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("operation %d of JSON patch must be a map", i)
+		}
+		if err := d.applyOperation(operation); err != nil {
+			return fmt.Errorf("operation %d (%s %s) failed: %v", i, strval(operation["op"]), strval(operation["path"]), err)
+		}
+	}
+	return nil
+}
+
+func (d *Document) applyOperation(operation map[string]interface{}) error {
+	path, hasPath := operation["path"].(string)
+	if !hasPath || (path != "" && !strings.HasPrefix(path, "/")) {
+		return fmt.Errorf("path must be a JSON pointer")
+	}
+	from, _ := operation["from"].(string)
+	value, hasValue := operation["value"]
+	switch operation["op"] {
+	case "add", "replace", "test":
+		if !hasValue {
+			return fmt.Errorf("value is required")
+		}
+	case "move", "copy":
+		if !strings.HasPrefix(from, "/") {
+			return fmt.Errorf("from must be a JSON pointer")
+		}
+	}
+	switch operation["op"] {
+	case "add":
+		return d.set(ParsePath(path), value, true)
+	case "remove":
+		return d.remove(path)
+	case "replace":
+		if _, exists, err := d.Get(path); err != nil || !exists {
+			return fmt.Errorf("path does not exist")
+		}
+		return d.set(ParsePath(path), value, false)
+	case "move":
+		moved, exists, err := d.Get(from)
+		if err != nil || !exists {
+			return fmt.Errorf("from does not exist")
+		}
+		if err := d.remove(from); err != nil {
+			return err
+		}
+		return d.set(ParsePath(path), moved, true)
+	case "copy":
+		copied, exists, err := d.Get(from)
+		if err != nil || !exists {
+			return fmt.Errorf("from does not exist")
+		}
+		return d.set(ParsePath(path), copied, true)
+	case "test":
+		actual, exists, err := d.Get(path)
+		if err != nil || !exists {
+			return fmt.Errorf("path does not exist")
+		}
+		if !jsonEqual(actual, value) {
+			return fmt.Errorf("value is %v, but expected %v", actual, value)
+		}
+		return nil
+	default:
+		return fmt.Errorf("op must be one of [add, remove, replace, move, copy, test]")
+	}
+}
+
+func (d *Document) remove(path string) error {
+	deleted, err := d.Delete(path)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return fmt.Errorf("path does not exist")
+	}
+	return nil
+}
+
+// jsonEqual compares values by their JSON representation, so that e.g. int 1 and float 1.0 are equal.
+func jsonEqual(a interface{}, b interface{}) bool {
+	normalize := func(v interface{}) interface{} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return v
+		}
+		var out interface{}
+		if err := json.Unmarshal(data, &out); err != nil {
+			return v
+		}
+		return out
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+// resolveNode follows the segments from the node. With create, missing keys are added as maps.
+// Without create, nil is returned if a key does not exist.
+func resolveNode(node *yaml.Node, segments []string, create bool) (*yaml.Node, error) {
+	for i, segment := range segments {
+		if node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+		switch node.Kind {
+		case yaml.MappingNode:
+			index := mappingIndex(node, segment)
+			if index < 0 {
+				if !create {
+					return nil, nil
+				}
+				child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				node.Content = append(node.Content, stringNode(segment), child)
+				node = child
+				continue
+			}
+			node = node.Content[index+1]
+		case yaml.SequenceNode:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node.Content) {
+				if !create {
+					return nil, nil
+				}
+				return nil, fmt.Errorf("%s is not an index of list %s", segment, strings.Join(segments[:i], "."))
+			}
+			node = node.Content[index]
+		default:
+			if !create {
+				return nil, nil
+			}
+			if node.Tag == "!!null" {
+				node.Kind, node.Tag, node.Value = yaml.MappingNode, "!!map", ""
+				child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				node.Content = append(node.Content, stringNode(segment), child)
+				node = child
+				continue
+			}
+			return nil, fmt.Errorf("%s is neither a map nor a list", strings.Join(segments[:i], "."))
+		}
+	}
+	return node, nil
+}
+
+func setChild(parent *yaml.Node, segments []string, value *yaml.Node, insert bool) error {
+	last := segments[len(segments)-1]
+	if parent.Kind == yaml.ScalarNode && parent.Tag == "!!null" {
+		parent.Kind, parent.Tag, parent.Value = yaml.MappingNode, "!!map", ""
+	}
+	switch parent.Kind {
+	case yaml.MappingNode:
+		if index := mappingIndex(parent, last); index >= 0 {
+			parent.Content[index+1] = keepComments(parent.Content[index+1], value)
+		} else {
+			parent.Content = append(parent.Content, stringNode(last), value)
+		}
+		return nil
+	case yaml.SequenceNode:
+		if last == "-" {
+			parent.Content = append(parent.Content, value)
+			return nil
+		}
+		index, err := strconv.Atoi(last)
+		if err != nil || index < 0 || index > len(parent.Content) {
+			return fmt.Errorf("%s is not an index of list %s", last, strings.Join(segments[:len(segments)-1], "."))
+		}
+		switch {
+		case index == len(parent.Content):
+			parent.Content = append(parent.Content, value)
+		case insert:
+			parent.Content = append(parent.Content[:index], append([]*yaml.Node{value}, parent.Content[index:]...)...)
+		default:
+			parent.Content[index] = keepComments(parent.Content[index], value)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s is neither a map nor a list", strings.Join(segments[:len(segments)-1], "."))
+	}
+}
+
+func mappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func stringNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+// keepComments copies the comments of a replaced node, so that they are not lost.
+func keepComments(old *yaml.Node, replacement *yaml.Node) *yaml.Node {
+	if old == nil {
+		return replacement
+	}
+	if replacement.HeadComment == "" {
+		replacement.HeadComment = old.HeadComment
+	}
+	if replacement.LineComment == "" {
+		replacement.LineComment = old.LineComment
+	}
+	if replacement.FootComment == "" {
+		replacement.FootComment = old.FootComment
+	}
+	return replacement
+}
+
+func toNode(value interface{}) (*yaml.Node, error) {
+	if node, ok := value.(*yaml.Node); ok {
+		return node, nil
+	}
+	node := &yaml.Node{}
+	if err := node.Encode(value); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// writeNodeAsJSON writes a node as compact JSON and keeps the order of keys.
+func writeNodeAsJSON(buf *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		return writeNodeAsJSON(buf, documentRoot(node))
+	case yaml.AliasNode:
+		return writeNodeAsJSON(buf, node.Alias)
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, err := json.Marshal(node.Content[i].Value)
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := writeNodeAsJSON(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, child := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeNodeAsJSON(buf, child); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		if tag := node.ShortTag(); (tag == "!!int" || tag == "!!float") && isJSONNumber(node.Value) {
+			buf.WriteString(node.Value)
+			return nil
+		}
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+	return nil
+}
+
+// isJSONNumber returns true, if the literal of a yaml number is also a JSON number, so that it can be written as it is.
+func isJSONNumber(literal string) bool {
+	return literal != "" && (literal[0] == '-' || (literal[0] >= '0' && literal[0] <= '9')) && json.Valid([]byte(literal))
+}
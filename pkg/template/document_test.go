@@ -0,0 +1,139 @@
+package template
+
+import (
+	"testing"
+)
+
+func TestDocumentRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		content  string
+		set      map[string]interface{}
+		expected string
+	}{
+		{
+			name:     "json with tabs",
+			format:   FormatJSON,
+			content:  "{\n\t\"a\": 1,\n\t\"b\": [1, 2]\n}\n",
+			expected: "{\n\t\"a\": 1,\n\t\"b\": [\n\t\t1,\n\t\t2\n\t]\n}\n",
+		},
+		{
+			name:     "json numbers",
+			format:   FormatJSON,
+			content:  "{\n  \"a\": 1.0,\n  \"b\": 2.50,\n  \"c\": 1e3\n}\n",
+			set:      map[string]interface{}{"d": 2},
+			expected: "{\n  \"a\": 1.0,\n  \"b\": 2.50,\n  \"c\": 1e3,\n  \"d\": 2\n}\n",
+		},
+		{
+			name:     "json compact",
+			format:   FormatJSON,
+			content:  `{"a":{"b":true}}`,
+			expected: "{\"a\":{\"b\":true}}\n",
+		},
+		{
+			name:     "yaml compact sequences",
+			format:   FormatYAML,
+			content:  "spec:\n  hosts:\n  - kafka-1\n  containers:\n  - name: a\n    args:\n    - --x\n    script: |\n      foo:\n        - bar\n  ratio: 1.0\n",
+			set:      map[string]interface{}{"spec.hosts.-": "kafka-2"},
+			expected: "spec:\n  hosts:\n  - kafka-1\n  - kafka-2\n  containers:\n  - name: a\n    args:\n    - --x\n    script: |\n      foo:\n        - bar\n  ratio: 1.0\n",
+		},
+		{
+			name:     "yaml indented sequences",
+			format:   FormatYAML,
+			content:  "spec:\n  hosts:\n    - kafka-1\n",
+			set:      map[string]interface{}{"spec.hosts.-": "kafka-2"},
+			expected: "spec:\n  hosts:\n    - kafka-1\n    - kafka-2\n",
+		},
+		{
+			name:     "yaml only comments",
+			format:   FormatYAML,
+			content:  "# generated\n",
+			set:      map[string]interface{}{"a": 1},
+			expected: "# generated\na: 1\n",
+		},
+		{
+			name:     "yaml mixed sequences",
+			format:   FormatYAML,
+			content:  "# values\n\nbrokers:\n- kafka-1\n\n# the topics\ntopics:\n    - name: a\n      partitions: 3   # aligned\nport: 9092\nzookeeper:\n  hosts:\n    - zk-1\n",
+			set:      map[string]interface{}{"port": 9093, "zookeeper.hosts.-": "zk-2", "acls": []interface{}{"read"}},
+			expected: "# values\n\nbrokers:\n- kafka-1\n\n# the topics\ntopics:\n    - name: a\n      partitions: 3   # aligned\nport: 9093\nzookeeper:\n  hosts:\n    - zk-1\n    - zk-2\nacls:\n- read\n",
+		},
+		{
+			name:     "properties separator",
+			format:   FormatProperties,
+			content:  "# header\n\n# a\na=1\n\n# b\nb=2\n",
+			set:      map[string]interface{}{"b": 3, "c": 4},
+			expected: "# header\n\n# a\na=1\n\n# b\nb=3\nc=4\n",
+		},
+		{
+			name:     "properties untouched lines",
+			format:   FormatProperties,
+			content:  "! header\nname=caf\\u00e9\n\npath = /a:\\\n  /b\n\n#last\nport: 1\nport: 2",
+			set:      map[string]interface{}{"port": 3, "new": "x"},
+			expected: "! header\nname=caf\\u00e9\n\npath = /a:\\\n  /b\n\n#last\nport=3\nport=3\nnew=x\n",
+		},
+		{
+			name:     "properties without entries",
+			format:   FormatProperties,
+			content:  "",
+			set:      map[string]interface{}{"a": 1},
+			expected: "a = 1\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc, err := LoadDocument(test.format, []byte(test.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for path, value := range test.set {
+				if err := doc.Set(path, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			out, err := doc.Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != test.expected {
+				t.Errorf("expected:\n%s\nbut got:\n%s", test.expected, out)
+			}
+		})
+	}
+}
+
+func TestDocumentDeleteProperties(t *testing.T) {
+	doc, err := LoadDocument(FormatProperties, []byte("# a\na=1\n\n! b\nb=2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted, err := doc.Delete("a"); err != nil || !deleted {
+		t.Fatalf("expected a to be deleted, but got %v (%v)", deleted, err)
+	}
+	out, err := doc.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "# a\n\n! b\nb=2\n"; string(out) != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, out)
+	}
+}
+
+func TestDetectSeparator(t *testing.T) {
+	tests := map[string]string{
+		"a=1\n":                "=",
+		"a = 1\n":              " = ",
+		"a: 1\n":               ": ",
+		"a 1\n":                " ",
+		"# x = y\n\n!c\nb:2\n": ":",
+		"a\\=b\\ c = 1\n":      " = ",
+		"":                     " = ",
+		"# only a comment=1\n": " = ",
+	}
+	for content, expected := range tests {
+		if separator := detectSeparator([]byte(content)); separator != expected {
+			t.Errorf("%q: expected separator %q, but got %q", content, expected, separator)
+		}
+	}
+}
@@ -13,6 +13,9 @@ type Engine interface {
 
 type Config struct {
 	Strict bool
+	// CollectErrors collects the errors of validation functions (e.g. required) and reports
+	// them together after rendering, instead of aborting on the first one.
+	CollectErrors bool
 }
 
 type engine struct {
@@ -28,7 +31,7 @@ func NewEngine(config Config) Engine {
 
 func (e *engine) initTemplate() {
 	e.tpl = template.New("gotpl")
-	e.tpl.Funcs(funcMap()).Funcs(template.FuncMap{"tpl": e.Render})
+	e.tpl.Funcs(funcMap()).Funcs(template.FuncMap{"tpl": e.Render}).Funcs(mappingFuncs(nil))
 }
 
 func (e *engine) AddReferenceTemplate(name string, renderable string) error {
@@ -46,10 +49,20 @@ func (e *engine) Render(renderable string, context map[string]interface{}) (rend
 		return "", fmt.Errorf("could not parse %v", err)
 	}
 
+	t.Funcs(mappingFuncs(t))
+
+	collector := &validationCollector{}
+	if e.config.CollectErrors {
+		t.Funcs(collectingFuncs(funcMap(), collector))
+	}
+
 	rendered, err = e.renderTemplate(t, context)
 	if err != nil {
 		return "", fmt.Errorf("could not render %v", err)
 	}
+	if err = collector.err(); err != nil {
+		return "", err
+	}
 
 	return
 }
@@ -0,0 +1,195 @@
+package template
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// envTreeOptions are the optional settings of envToTree, passed as dict, e.g.
+//
+//	{{ envToTree "APP_" (dict "separator" "_" "infer" true) | toYAML }}
+type envTreeOptions struct {
+	separator string
+	lowerCase bool
+	infer     bool
+}
+
+func parseEnvTreeOptions(options []interface{}) (envTreeOptions, error) {
+	parsed := envTreeOptions{separator: "__", lowerCase: true}
+	if len(options) == 0 {
+		return parsed, nil
+	}
+	if len(options) > 1 {
+		return parsed, fmt.Errorf("expected at most one options dict, but got %d arguments", len(options))
+	}
+	optionsVal := reflect.ValueOf(options[0])
+	if optionsVal.Kind() != reflect.Map {
+		return parsed, fmt.Errorf("options must be a dict but was %T", options[0])
+	}
+	iter := optionsVal.MapRange()
+	for iter.Next() {
+		key := strval(iter.Key().Interface())
+		value := strval(iter.Value().Interface())
+		switch key {
+		case "separator":
+			if value == "" {
+				return parsed, fmt.Errorf("separator must not be empty")
+			}
+			parsed.separator = value
+		case "case":
+			if value != keyCaseLower && value != keyCasePreserve {
+				return parsed, fmt.Errorf("case must be one of [%s, %s], but was: %s", keyCaseLower, keyCasePreserve, value)
+			}
+			parsed.lowerCase = value == keyCaseLower
+		case "infer":
+			infer, err := strconv.ParseBool(value)
+			if err != nil {
+				return parsed, fmt.Errorf("infer must be a bool, but was: %s", value)
+			}
+			parsed.infer = infer
+		default:
+			return parsed, fmt.Errorf("unknown option %s, supported are [separator, case, infer]", key)
+		}
+	}
+	return parsed, nil
+}
+
+// envToTree builds a nested structure of maps and lists from environment variables with a prefix.
+// The names are split by the separator (default '__') into a path and lower cased. Maps whose keys
+// are all numeric indexes become lists. With the option infer, values are converted to bool, int,
+// float or null, if possible.
+//
+//	APP_SERVER__PORT=8080 APP_SERVER__HOSTS__0=a APP_SERVER__HOSTS__1=b APP_LOG_LEVEL=info
+//	{{ envToTree "APP_" (dict "infer" true) | toYAML }}
+//
+//	log_level: info
+//	server:
+//	  hosts:
+//	  - a
+//	  - b
+//	  port: 8080
+func envToTree(prefix string, options ...interface{}) (interface{}, error) {
+	opts, err := parseEnvTreeOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	env := envToMap(prefix)
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tree := make(map[string]interface{})
+	for _, name := range names {
+		path := strings.TrimPrefix(name, prefix)
+		if opts.lowerCase {
+			path = strings.ToLower(path)
+		}
+		segments := strings.Split(path, opts.separator)
+		for _, segment := range segments {
+			if segment == "" {
+				return nil, fmt.Errorf("%s has an empty path segment", name)
+			}
+		}
+		var value interface{} = env[name]
+		if opts.infer {
+			value = inferType(strval(value))
+		}
+		if err := setTreeValue(tree, segments, value); err != nil {
+			return nil, fmt.Errorf("%s %v", name, err)
+		}
+	}
+	return treeToLists(tree, "")
+}
+
+func setTreeValue(tree map[string]interface{}, segments []string, value interface{}) error {
+	node := tree
+	for i, segment := range segments[:len(segments)-1] {
+		child, exists := node[segment]
+		if !exists {
+			child = make(map[string]interface{})
+			node[segment] = child
+		}
+		childMap, isMap := child.(map[string]interface{})
+		if !isMap {
+			return fmt.Errorf("conflicts with value of %s", strings.Join(segments[:i+1], "."))
+		}
+		node = childMap
+	}
+	last := segments[len(segments)-1]
+	if _, exists := node[last]; exists {
+		return fmt.Errorf("conflicts with nested values of %s", strings.Join(segments, "."))
+	}
+	node[last] = value
+	return nil
+}
+
+// treeToLists replaces maps whose keys are the indexes 0 to n-1 with lists.
+func treeToLists(node interface{}, path string) (interface{}, error) {
+	nodeMap, isMap := node.(map[string]interface{})
+	if !isMap {
+		return node, nil
+	}
+	for key, child := range nodeMap {
+		converted, err := treeToLists(child, joinTreePath(path, key))
+		if err != nil {
+			return nil, err
+		}
+		nodeMap[key] = converted
+	}
+	indexes := make([]int, 0, len(nodeMap))
+	for key := range nodeMap {
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || strconv.Itoa(index) != key {
+			return nodeMap, nil
+		}
+		indexes = append(indexes, index)
+	}
+	if len(indexes) == 0 {
+		return nodeMap, nil
+	}
+	sort.Ints(indexes)
+	list := make([]interface{}, len(indexes))
+	for i, index := range indexes {
+		if i != index {
+			return nil, fmt.Errorf("list %s has no index %d", path, i)
+		}
+		list[i] = nodeMap[strconv.Itoa(index)]
+	}
+	return list, nil
+}
+
+func joinTreePath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// inferType converts a text into bool, int64, float64 or nil, if it is exactly such a value.
+// Numbers with leading zeros (e.g. file modes like 0755) stay text.
+func inferType(text string) interface{} {
+	switch text {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	digits := strings.TrimPrefix(text, "-")
+	if len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+		return text
+	}
+	if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil && digits != "" && digits[0] >= '0' && digits[0] <= '9' {
+		return f
+	}
+	return text
+}
@@ -0,0 +1,180 @@
+package template
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lookupEnvValue returns the trimmed value of an environment variable. Empty values are treated
+// like unset variables. If the variable is not set, the default is returned, or an error if
+// there is no default.
+func lookupEnvValue(name string, fallback []interface{}) (string, interface{}, bool, error) {
+	if len(fallback) > 1 {
+		return "", nil, false, fmt.Errorf("expected at most one default for environment variable %s, but got %d arguments", name, len(fallback))
+	}
+	if value, ok := os.LookupEnv(name); ok && strings.TrimSpace(value) != "" {
+		return strings.TrimSpace(value), nil, true, nil
+	}
+	if len(fallback) == 1 {
+		return "", fallback[0], false, nil
+	}
+	return "", nil, false, fmt.Errorf("environment variable %s is not set", name)
+}
+
+// envInt returns the value of an environment variable as int. Malformed values result in an error,
+// even if a default is defined.
+//
+//	num.partitions={{ envInt "NUM_PARTITIONS" 3 }}
+func envInt(name string, fallback ...interface{}) (int, error) {
+	value, def, found, err := lookupEnvValue(name, fallback)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		i, err := strconv.Atoi(strval(def))
+		if err != nil {
+			return 0, fmt.Errorf("default of environment variable %s must be an int, but was: %v", name, def)
+		}
+		return i, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("environment variable %s must be an int, but was: %s", name, value)
+	}
+	return i, nil
+}
+
+// envBool returns the value of an environment variable as bool. Besides the values supported
+// by strconv.ParseBool (e.g. true, FALSE, 1), yes/no and on/off are accepted.
+//
+//	{{ if envBool "TLS_ENABLED" false }}ssl.enabled=true{{ end }}
+func envBool(name string, fallback ...interface{}) (bool, error) {
+	value, def, found, err := lookupEnvValue(name, fallback)
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		if b, ok := def.(bool); ok {
+			return b, nil
+		}
+		b, err := parseBool(strval(def))
+		if err != nil {
+			return false, fmt.Errorf("default of environment variable %s must be a bool, but was: %v", name, def)
+		}
+		return b, nil
+	}
+	b, err := parseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("environment variable %s must be a bool (true/false, yes/no, on/off, 1/0), but was: %s", name, value)
+	}
+	return b, nil
+}
+
+func parseBool(text string) (bool, error) {
+	switch strings.ToLower(text) {
+	case "yes", "on":
+		return true, nil
+	case "no", "off":
+		return false, nil
+	}
+	return strconv.ParseBool(text)
+}
+
+// envDuration returns the value of an environment variable as duration (e.g. 1m30s).
+// Numbers without unit are seconds.
+//
+//	session.timeout.ms={{ (envDuration "SESSION_TIMEOUT" "45s").Milliseconds }}
+func envDuration(name string, fallback ...interface{}) (time.Duration, error) {
+	value, def, found, err := lookupEnvValue(name, fallback)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		d, err := toDuration(def)
+		if err != nil {
+			return 0, fmt.Errorf("default of environment variable %s must be a duration, but was: %v", name, def)
+		}
+		return d, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("environment variable %s must be a duration (e.g. 30s, 1m30s), but was: %s", name, value)
+	}
+	return d, nil
+}
+
+// envList splits the value of an environment variable by the separator. Entries are trimmed
+// and empty entries are removed. The default can be a list or a text, which is split as well.
+//
+//	{{ range envList "ZOOKEEPER_SERVERS" "," (list "localhost:2181") }}{{ . }}{{ end }}
+func envList(name string, separator string, fallback ...interface{}) ([]string, error) {
+	if separator == "" {
+		return nil, fmt.Errorf("separator must not be empty")
+	}
+	value, def, found, err := lookupEnvValue(name, fallback)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if kind := reflect.ValueOf(def).Kind(); kind == reflect.Slice || kind == reflect.Array {
+			return toFlatListOfStrings(def), nil
+		}
+		return splitList(strval(def), separator), nil
+	}
+	return splitList(value, separator), nil
+}
+
+func splitList(text string, separator string) []string {
+	list := make([]string, 0)
+	for _, entry := range strings.Split(text, separator) {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// envMap parses the value of an environment variable as comma separated key=value pairs, like
+// kvCsvToMap. In contrast to kvCsvToMap, entries without '=' result in an error. The default can
+// be a dict or a text in the same format.
+//
+//	{{ range $key, $value := envMap "LOGGERS" (dict) }}log4j.logger.{{ $key }}={{ $value }}{{ end }}
+func envMap(name string, fallback ...interface{}) (map[string]interface{}, error) {
+	value, def, found, err := lookupEnvValue(name, fallback)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if reflect.ValueOf(def).Kind() == reflect.Map {
+			result := make(map[string]interface{})
+			iter := reflect.ValueOf(def).MapRange()
+			for iter.Next() {
+				result[strval(iter.Key().Interface())] = iter.Value().Interface()
+			}
+			return result, nil
+		}
+		return parseKvList(strval(def))
+	}
+	result, err := parseKvList(value)
+	if err != nil {
+		return nil, fmt.Errorf("environment variable %s %v", name, err)
+	}
+	return result, nil
+}
+
+// parseKvList parses comma separated key=value pairs with kvCsvToMapWith. Keys and values are trimmed,
+// and entries without '=' or with an empty key are an error.
+func parseKvList(text string) (map[string]interface{}, error) {
+	result, err := kvCsvToMapWith(map[string]interface{}{"trim": true, "strict": true}, text)
+	if err != nil {
+		return nil, fmt.Errorf("must be a list of key=value pairs: %v", err)
+	}
+	return result, nil
+}
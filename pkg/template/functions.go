@@ -6,13 +6,11 @@ import (
 	"fmt"
 	"github.com/BurntSushi/toml"
 	"github.com/Masterminds/sprig"
-	"github.com/magiconair/properties"
 	"github.com/mitchellh/mapstructure"
-	"gopkg.in/yaml.v3"
+	"go.yaml.in/yaml/v3"
 	"net"
 	"os"
 	"reflect"
-	"regexp"
 	"strings"
 	"text/template"
 )
@@ -25,40 +23,151 @@ func funcMap() template.FuncMap {
 		"heygodub": func() string { return "Hello :)" },
 
 		// Env functions
-		"hasEnv":    hasEnv,
-		"fromEnv":   fromEnv,
-		"envToMap":  envToMap,
-		"envToProp": envToProp,
+		"hasEnv":      hasEnv,
+		"fromEnv":     fromEnv,
+		"envToMap":    envToMap,
+		"envToProp":   envToProp,
+		"envToConfig": envToConfig,
+		"envToTree":   envToTree,
+		"envInt":      envInt,
+		"envBool":     envBool,
+		"envDuration": envDuration,
+		"envList":     envList,
+		"envMap":      envMap,
 
 		// Map functions
-		"excludeKeys":      excludeKeys,
-		"replaceKeyPrefix": replaceKeyPrefix,
-		"toPropertiesKey":  toPropertiesKey,
+		"excludeKeys":        excludeKeys,
+		"includeKeys":        includeKeys,
+		"renameKeys":         renameKeys,
+		"filterByValue":      filterByValue,
+		"replaceKeyPrefix":   replaceKeyPrefix,
+		"toPropertiesKey":    toPropertiesKey,
+		"propToEnv":          propToEnv,
+		"propertiesKeyToEnv": propertiesKeyToEnv,
+		"toConfigKey":        toConfigKey,
+		"configToEnv":        configToEnv,
+		"orderedDict":        orderedDict,
+		"toOrderedMap":       toOrderedMap,
+		"deepMerge":          deepMerge,
+		"deepMergeWith":      deepMergeWith,
+		"getPath":            getPath,
+		"setPath":            setPath,
+		"deletePath":         deletePath,
+		"flatten":            flatten,
+		"flattenWith":        flattenWith,
+		"unflatten":          unflatten,
+		"unflattenWith":      unflattenWith,
+		"mapDiff":            mapDiff,
 
 		// String functions
-		"kvCsvToMap": kvCsvToMap,
+		"kvCsvToMap":     kvCsvToMap,
+		"kvCsvToMapWith": kvCsvToMapWith,
+		"mapToKvCsv":     mapToKvCsv,
+		"mapToKvCsvWith": mapToKvCsvWith,
+		"fromCSV":        fromCSV,
+		"fromCSVWith":    fromCSVWith,
+		"toCSV":          toCSV,
+		"toCSVWith":      toCSVWith,
 
 		// List functions
 		"filterHasPrefix": filterHasPrefix,
+		"filterHasSuffix": filterHasSuffix,
+		"filterContains":  filterContains,
+		"filterMatch":     filterMatch,
+		"rejectHasPrefix": rejectHasPrefix,
+		"rejectHasSuffix": rejectHasSuffix,
+		"rejectContains":  rejectContains,
+		"rejectMatch":     rejectMatch,
+		"partition":       partition,
+		"uniq":            uniq,
+		"sortBy":          sortBy,
 
 		// Verify functions
-		"required": required,
+		"required":    required,
+		"requiredEnv": requiredEnv,
+		"mustMatch":   mustMatch,
+		"oneOf":       oneOf,
+		"inRange":     inRange,
+		"validURL":    validURL,
 
 		// Network functions
 		"ipAddresses":  ipAddresses,
 		"ipAddress":    ipAddress,
 		"anyIpAddress": anyIpAddress,
 
+		// Interface-aware network functions
+		"interfaceAddresses": interfaceAddresses,
+		"onInterface":        onInterface,
+		"inCidr":             inCidr,
+		"notInCidr":          notInCidr,
+		"withIpVersion":      withIpVersion,
+		"preferInterfaces":   preferInterfaces,
+		"interfaceIpAddress": interfaceIpAddress,
+		"cidrIpAddress":      cidrIpAddress,
+
+		// Address functions
+		"joinHostPort":   joinHostPort,
+		"splitHostPort":  splitHostPort,
+		"parseEndpoint":  parseEndpoint,
+		"parseEndpoints": parseEndpoints,
+		"canonicalIp":    canonicalIp,
+		"isIp":           isIp,
+		"isIpv4":         isIpv4,
+		"isIpv6":         isIpv6,
+		"cidrContains":   cidrContains,
+		"cidrHost":       cidrHost,
+		"cidrNetwork":    cidrNetwork,
+		"cidrNetmask":    cidrNetmask,
+		"cidrBroadcast":  cidrBroadcast,
+		"cidrPrefixLen":  cidrPrefixLen,
+
+		// Route functions
+		"routes":           routes,
+		"defaultRoute":     defaultRoute,
+		"routeTo":          routeTo,
+		"defaultIpAddress": defaultIpAddress,
+		"sourceIpAddress":  sourceIpAddress,
+
+		// DNS functions
+		"lookupHost":  lookupHost,
+		"lookupSRV":   lookupSRV,
+		"lookupAddr":  lookupAddr,
+		"lookupCNAME": lookupCNAME,
+		"hostname":    hostname,
+		"fqdn":        fqdn,
+
+		// Kafka functions
+		"kafkaProtocolMap":         kafkaProtocolMap,
+		"kafkaListeners":           kafkaListeners,
+		"kafkaValidateListeners":   kafkaValidateListeners,
+		"kafkaAdvertisedListeners": kafkaAdvertisedListeners,
+		"kafkaListenerPrefix":      kafkaListenerPrefix,
+		"kafkaListenerProps":       kafkaListenerProps,
+
+		// TLS functions
+		"parseCert":      parseCert,
+		"parseCerts":     parseCerts,
+		"certMatchesKey": certMatchesKey,
+		"pemBundle":      pemBundle,
+		"genCert":        genCert,
+		"keystore":       keystore,
+		"truststore":     truststore,
+
+		// Query functions
+		"jsonPath": jsonPath,
+		"query":    query,
+
 		// Format functions
-		"toYAML":         toYAML,
-		"fromYAML":       fromYAML,
-		"toJSON":         toJSON,
-		"toJSONPretty":   toJSONPretty,
-		"fromJSON":       fromJSON,
-		"toTOML":         toTOML,
-		"fromTOML":       fromTOML,
-		"toProperties":   toProperties,
-		"fromProperties": fromProperties,
+		"toYAML":           toYAML,
+		"fromYAML":         fromYAML,
+		"toJSON":           toJSON,
+		"toJSONPretty":     toJSONPretty,
+		"fromJSON":         fromJSON,
+		"toTOML":           toTOML,
+		"fromTOML":         fromTOML,
+		"toProperties":     toProperties,
+		"toPropertiesWith": toPropertiesWith,
+		"fromProperties":   fromProperties,
 	}
 
 	for k, v := range extra {
@@ -130,33 +239,27 @@ func envToMap(prefix string) map[string]interface{} {
 //
 // Returns:
 //
-//	Map of matching properties.
+//	OrderedMap of matching properties, in alphabetical order.
 //
 // See:
 //
 //	Original dub: https://github.com/confluentinc/confluent-docker-utils/blob/master/confluent/docker_utils/dub.py
-func envToProp(env_prefix string, prop_prefix string, exclude ...interface{}) map[string]interface{} {
-	return toPropertiesKey(replaceKeyPrefix(env_prefix, prop_prefix, excludeKeys(exclude, envToMap(env_prefix))))
+func envToProp(env_prefix string, prop_prefix string, exclude ...interface{}) *OrderedMap {
+	props, err := envToConfig("confluent", env_prefix, prop_prefix, exclude...)
+	if err != nil {
+		panic(err)
+	}
+	ordered := NewOrderedMap()
+	for key, value := range props {
+		ordered.Set(key, value)
+	}
+	return ordered.Sorted()
 }
 
+// excludeKeys returns the entries of a map whose keys match none of the patterns
+// (names, globs like KAFKA_*_OPTS or regular expressions like /^KAFKA_.*$/).
 func excludeKeys(exclude interface{}, sourceMap interface{}) map[string]interface{} {
-	sourceMapVal := reflect.ValueOf(sourceMap)
-	switch sourceMapVal.Kind() {
-	case reflect.Map:
-		resultMap := make(map[string]interface{})
-		excludeStrings := toFlatListOfStrings(exclude)
-		iter := sourceMapVal.MapRange()
-		for iter.Next() {
-			key := strval(iter.Key().Interface())
-			value := iter.Value().Interface()
-			if !contains(excludeStrings, key) {
-				resultMap[key] = value
-			}
-		}
-		return resultMap
-	default:
-		panic(fmt.Errorf("must be a map but was %T", sourceMap))
-	}
+	return filterKeys(mustKeyMatcher(exclude), false, sourceMap)
 }
 
 func strval(v interface{}) string {
@@ -177,7 +280,7 @@ func strval(v interface{}) string {
 }
 
 func replaceKeyPrefix(prefix string, replacement string, sourceMap interface{}) map[string]interface{} {
-	sourceMapVal := reflect.ValueOf(sourceMap)
+	sourceMapVal := mapValueOf(sourceMap)
 	switch sourceMapVal.Kind() {
 	case reflect.Map:
 		resultMap := make(map[string]interface{})
@@ -194,25 +297,15 @@ func replaceKeyPrefix(prefix string, replacement string, sourceMap interface{})
 }
 
 func toPropertiesKey(sourceMap interface{}) map[string]interface{} {
-	sourceMapVal := reflect.ValueOf(sourceMap)
+	sourceMapVal := mapValueOf(sourceMap)
 	switch sourceMapVal.Kind() {
 	case reflect.Map:
 		props := make(map[string]interface{})
-		to_dot_pattern := regexp.MustCompile("[^_](_)[^_]")
 		iter := sourceMapVal.MapRange()
 		for iter.Next() {
 			key := strval(iter.Key().Interface())
 			value := iter.Value().Interface()
-			raw_name := strings.ToLower(key)
-			var prop_dot string = raw_name
-			for matches := to_dot_pattern.FindAllString(prop_dot, -1); len(matches) > 0; matches = to_dot_pattern.FindAllString(prop_dot, -1) {
-				for _, frac := range matches {
-					prop_dot = strings.Replace(prop_dot, frac, strings.ReplaceAll(frac, "_", "."), 1)
-				}
-			}
-			prop_dash := strings.Join(strings.Split(prop_dot, "___"), "-")
-			prop_underscore := strings.Join(strings.Split(prop_dash, "__"), "_")
-			props[prop_underscore] = value
+			props[confluentKeyStyle.EnvToKey(key)] = value
 		}
 		return props
 	default:
@@ -220,6 +313,62 @@ func toPropertiesKey(sourceMap interface{}) map[string]interface{} {
 	}
 }
 
+// Converts properties with a prefix into environment variables. This is the inverse
+// of envToProp. Naming convention is to replace '_' with '__', '-' with '___' and
+// '.' with '_', and to convert the result to upper case.
+//
+// For example: for the properties
+//
+//	{
+//		'confluent.controlcenter.streams.security.protocol': 'SASL_SSL',
+//		'confluent.controlcenter.streams.with_underscore': 'foo',
+//		'confluent.controlcenter.streams.with-dash': 'bar'
+//	}
+//
+// then
+//
+//	propToEnv 'confluent.controlcenter.streams.' 'CONTROL_CENTER_STREAMS_' $props
+//
+// will produce
+//
+//	{
+//		'CONTROL_CENTER_STREAMS_SECURITY_PROTOCOL': 'SASL_SSL',
+//		'CONTROL_CENTER_STREAMS_WITH__UNDERSCORE': 'foo',
+//		'CONTROL_CENTER_STREAMS_WITH___DASH': 'bar'
+//	}
+//
+// Properties without the prefix are ignored.
+func propToEnv(prop_prefix string, env_prefix string, props interface{}) map[string]interface{} {
+	sourceMapVal := mapValueOf(props)
+	switch sourceMapVal.Kind() {
+	case reflect.Map:
+		env := make(map[string]interface{})
+		iter := sourceMapVal.MapRange()
+		for iter.Next() {
+			key := strval(iter.Key().Interface())
+			if strings.HasPrefix(key, prop_prefix) {
+				env[env_prefix+confluentKeyStyle.keyToEnv(strings.TrimPrefix(key, prop_prefix))] = iter.Value().Interface()
+			}
+		}
+		return env
+	default:
+		panic(fmt.Errorf("must be a map but was %T", props))
+	}
+}
+
+// propertiesKeyToEnv is the inverse of toPropertiesKey.
+func propertiesKeyToEnv(sourceMap interface{}) map[string]interface{} {
+	return propToEnv("", "", sourceMap)
+}
+
+// PropertyToEnv converts a property name into the name of the environment variable, which
+// is converted back into the same property by envToProp. If this is not possible, because
+// the name is ambiguous (e.g. contains upper case letters or consecutive separators), an
+// error is returned together with the best possible name.
+func PropertyToEnv(propPrefix string, envPrefix string, property string) (string, error) {
+	return confluentKeyStyle.PropertyToEnv(propPrefix, envPrefix, property)
+}
+
 // Parses a list of key/value pairs separated by commas.
 //
 // For example for "foo.bar=DEBUG,baz.bam=TRACE"
@@ -228,7 +377,8 @@ func toPropertiesKey(sourceMap interface{}) map[string]interface{} {
 //
 // Args:
 //
-//	kvList: String containing the comma separated list of key/value pairs.
+//	kvList: String containing the comma separated list of key/value pairs. Entries without '='
+//	are skipped. For quotes and more options, see kvCsvToMapWith.
 //
 // Returns:
 //
@@ -238,69 +388,13 @@ func toPropertiesKey(sourceMap interface{}) map[string]interface{} {
 //
 //	Original dub: https://github.com/confluentinc/confluent-docker-utils/blob/master/confluent/docker_utils/dub.py
 func kvCsvToMap(kvList string) map[string]interface{} {
-	props := make(map[string]interface{})
-	for _, override := range strings.Split(kvList, ",") {
-		tokens := strings.SplitN(override, "=", 2)
-		if len(tokens) == 2 {
-			props[tokens[0]] = tokens[1]
-		}
+	props, err := kvCsvToMapWith(map[string]interface{}{}, kvList)
+	if err != nil {
+		panic(err)
 	}
 	return props
 }
 
-func filterHasPrefix(prefix string, list interface{}) (interface{}, error) {
-	tp := reflect.TypeOf(list).Kind()
-	switch tp {
-	case reflect.Slice, reflect.Array:
-		filteredList := make([]interface{}, 0)
-
-		l2 := reflect.ValueOf(list)
-
-		if l2.Len() == 0 {
-			return filteredList, nil
-		}
-
-		for i := 0; i < l2.Len(); i++ {
-			result, err := filterHasPrefix(prefix, l2.Index(i).Interface())
-			if err != nil {
-				return nil, err
-			}
-			if result != nil {
-				filteredList = append(filteredList, result)
-			}
-		}
-
-		return filteredList, nil
-	default:
-		switch t := list.(type) {
-		case string:
-			if strings.HasPrefix(t, prefix) {
-				return t, nil
-			}
-			return nil, nil
-		case fmt.Stringer:
-			if strings.HasPrefix(t.String(), prefix) {
-				return t, nil
-			}
-			return nil, nil
-		case []byte:
-			if strings.HasPrefix(string(t), prefix) {
-				return t, nil
-			}
-			return nil, nil
-		case error:
-			if strings.HasPrefix(t.Error(), prefix) {
-				return t, nil
-			}
-			return nil, nil
-		case nil:
-			return nil, nil
-		default:
-			return nil, fmt.Errorf("should be type of slice, array, string, fmt.Stringer, []byte or error, but %s", tp)
-		}
-	}
-}
-
 // contains checks if a string is present in a slice
 func contains(s []string, str string) bool {
 	for _, v := range s {
@@ -314,6 +408,9 @@ func contains(s []string, str string) bool {
 func toFlatListOfStrings(args ...interface{}) []string {
 	stringList := make([]string, 0)
 	for _, arg := range args {
+		if arg == nil {
+			continue
+		}
 		switch reflect.TypeOf(arg).Kind() {
 		case reflect.Slice, reflect.Array:
 			value := reflect.ValueOf(arg)
@@ -322,8 +419,6 @@ func toFlatListOfStrings(args ...interface{}) []string {
 			}
 		default:
 			switch t := arg.(type) {
-			case nil:
-				break
 			case string:
 				stringList = append(stringList, t)
 			case []byte:
@@ -340,17 +435,6 @@ func toFlatListOfStrings(args ...interface{}) []string {
 	return stringList
 }
 
-func required(warn string, val interface{}) (interface{}, error) {
-	if val == nil || reflect.ValueOf(val).IsNil() {
-		return nil, fmt.Errorf(warn)
-	} else if _, ok := val.(string); ok {
-		if val == "" || val == "<nil>" {
-			return nil, fmt.Errorf(warn)
-		}
-	}
-	return val, nil
-}
-
 const (
 	require string = "require"
 	prefer  string = "prefer"
@@ -494,38 +578,63 @@ func fromTOML(text string) (interface{}, error) {
 }
 
 // toProperties takes an interface, marshals it to properties, and returns a string.
+// Keys of maps are written in alphabetical order, keys of an OrderedMap in insertion order.
 func toProperties(v interface{}) (string, error) {
-	ofStrings, err := toPropertiesStringMap(v)
-	if err != nil {
-		return "", err
-	}
-	props := properties.LoadMap(ofStrings)
-	return props.String(), nil
+	return toPropertiesWith(map[string]interface{}{}, v)
 }
 
-func fromProperties(text string) (map[string]interface{}, error) {
-	propsMap := make(map[string]interface{})
-	props, err := properties.LoadString(text)
-	for key, value := range props.Map() {
-		propsMap[key] = value
+// toPropertiesWith is toProperties with options, passed as dict, e.g.
+//
+//	{{ toPropertiesWith (dict "separator" "=" "escape" true "header" "generated by godub") $props }}
+//
+// Supported options are sort (also sorts an OrderedMap), separator (' = ', '=', ':' or ' : '),
+// escape (escapes special characters), escapeUnicode (writes non ASCII characters as \uXXXX),
+// expand (default true, resolves ${...} in values with other properties and environment variables),
+// comments (dict of key to comment) and header (comment at the top).
+func toPropertiesWith(options interface{}, v interface{}) (string, error) {
+	opts, err := parsePropertiesOptions(options)
+	if err != nil {
+		return "", err
 	}
+	props, err := toPropertiesOrderedMap(v)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	if opts.sort {
+		props = props.Sorted()
 	}
-	return propsMap, nil
+	if opts.expand {
+		if err := expandProperties(props); err != nil {
+			return "", err
+		}
+	}
+	return writeProperties(props, opts), nil
 }
 
-func toPropertiesStringMap(v interface{}) (map[string]string, error) {
+// toPropertiesOrderedMap flattens an interface into properties with string values. Keys of maps are
+// sorted, and lists of maps are merged in order.
+func toPropertiesOrderedMap(v interface{}) (*OrderedMap, error) {
 	switch t := v.(type) {
 	case nil:
-		return map[string]string{}, nil
-	case map[string]string:
-		return t, nil
+		return NewOrderedMap(), nil
+	case *OrderedMap:
+		props := NewOrderedMap()
+		for _, entry := range t.Entries() {
+			stringValue, err := toPropertiesString(entry.Value)
+			if err != nil {
+				return nil, err
+			}
+			props.Set(entry.Key, stringValue)
+			if entry.Comment != "" {
+				props.SetComment(entry.Key, entry.Comment)
+			}
+		}
+		return props, nil
 	default:
 		val := reflect.ValueOf(v)
 		switch val.Kind() {
 		case reflect.Map:
-			stringMap := make(map[string]string)
+			props := NewOrderedMap()
 			iter := val.MapRange()
 			for iter.Next() {
 				key := iter.Key().Interface()
@@ -538,36 +647,39 @@ func toPropertiesStringMap(v interface{}) (map[string]string, error) {
 				if err != nil {
 					return nil, err
 				}
-				stringMap[stringKey] = stringValue
+				props.Set(stringKey, stringValue)
 			}
-			return stringMap, nil
+			return props.Sorted(), nil
 		case reflect.Array, reflect.Slice:
-			stringMap := make(map[string]string)
+			props := NewOrderedMap()
 			l := val.Len()
 			for i := 0; i < l; i++ {
 				value := val.Index(i).Interface()
-				subMap, err := toPropertiesStringMap(value)
+				subProps, err := toPropertiesOrderedMap(value)
 				if err != nil {
 					return nil, err
 				}
-				for subKey, subValue := range subMap {
-					stringMap[subKey] = subValue
+				for _, entry := range subProps.Entries() {
+					props.Set(entry.Key, entry.Value)
+					if entry.Comment != "" {
+						props.SetComment(entry.Key, entry.Comment)
+					}
 				}
 			}
-			return stringMap, nil
+			return props, nil
 		}
 		if isStruct(t) {
 			m, err := structToMap(t)
 			if err != nil {
 				return nil, err
 			}
-			return toPropertiesStringMap(m)
+			return toPropertiesOrderedMap(m)
 		}
 		stringKey, err := toPropertiesString(t)
 		if err != nil {
 			return nil, err
 		}
-		return map[string]string{stringKey: ""}, nil
+		return NewOrderedMap().Set(stringKey, ""), nil
 	}
 }
 
@@ -581,6 +693,16 @@ func toPropertiesString(v interface{}) (string, error) {
 		return string(t), nil
 	case error:
 		return t.Error(), nil
+	case *OrderedMap:
+		stringList := make([]string, 0, t.Len())
+		for _, entry := range t.Entries() {
+			stringValue, err := toPropertiesString(entry.Value)
+			if err != nil {
+				return "", err
+			}
+			stringList = append(stringList, fmt.Sprintf("%s=%s", entry.Key, stringValue))
+		}
+		return strings.Join(stringList, ","), nil
 	case fmt.Stringer:
 		return t.String(), nil
 	default:
@@ -0,0 +1,761 @@
+package template
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// JSONPath is a compiled JSONPath expression. The supported syntax is:
+//
+//	$                   the root (optional, .a and a are the same as $.a)
+//	.name ['name']      a key of a map
+//	[0] [-1]            an index of a list, negative indexes count from the end
+//	[0,2] ['a','b']     a union of indexes or keys
+//	[1:3] [::2]         a slice of a list with start, end and step
+//	.* [*]              all values of a map or list
+//	..name ..*          recursive descent
+//	[?(@.port > 1024)]  filter with the operators ==, !=, <, <=, >, >=, =~ (regex), &&, || and !
+//
+// In filters, @ is the current value and $ the root. A path without operator checks if the value exists
+// and is neither false nor null.
+type JSONPath struct {
+	expression string
+	steps      []pathStep
+}
+
+type pathStep struct {
+	recursive bool
+	selector  pathSelector
+}
+
+type pathSelector interface {
+	selectFrom(value interface{}, root interface{}) []interface{}
+	definite() bool
+}
+
+// CompileJSONPath parses a JSONPath expression.
+func CompileJSONPath(expression string) (*JSONPath, error) {
+	p := &pathParser{text: strings.TrimSpace(expression)}
+	if strings.HasPrefix(p.text, "$") {
+		p.pos = 1
+	} else if p.text != "" && p.text[0] != '.' && p.text[0] != '[' {
+		p.text = "." + p.text
+	}
+	steps, err := p.parseSteps(false)
+	if err == nil && p.pos < len(p.text) {
+		err = p.errorf("unexpected '%s'", p.text[p.pos:])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONPath %s: %v", expression, err)
+	}
+	return &JSONPath{expression: expression, steps: steps}, nil
+}
+
+// Find returns all values matching the path.
+func (jp *JSONPath) Find(root interface{}) []interface{} {
+	return evaluateSteps(jp.steps, root, root)
+}
+
+// Definite checks if the path matches at most one value, which is the case if it contains
+// neither wildcards, recursive descents, filters, slices nor unions.
+func (jp *JSONPath) Definite() bool {
+	for _, step := range jp.steps {
+		if step.recursive || !step.selector.definite() {
+			return false
+		}
+	}
+	return true
+}
+
+// Query returns the value of a definite path, or nil if it does not exist, and the list of
+// matching values for all other paths.
+func (jp *JSONPath) Query(data interface{}) interface{} {
+	values := jp.Find(data)
+	if jp.Definite() {
+		if len(values) == 0 {
+			return nil
+		}
+		return values[0]
+	}
+	return values
+}
+
+func evaluateSteps(steps []pathStep, current interface{}, root interface{}) []interface{} {
+	values := []interface{}{current}
+	for _, step := range steps {
+		next := make([]interface{}, 0)
+		for _, value := range values {
+			candidates := []interface{}{value}
+			if step.recursive {
+				candidates = descendants(value)
+			}
+			for _, candidate := range candidates {
+				next = append(next, step.selector.selectFrom(candidate, root)...)
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+// descendants returns the value and all nested values, depth first.
+func descendants(value interface{}) []interface{} {
+	result := []interface{}{value}
+	for _, child := range childValues(value) {
+		result = append(result, descendants(child)...)
+	}
+	return result
+}
+
+// childValues returns the values of a map (sorted by key) or the elements of a list.
+func childValues(value interface{}) []interface{} {
+	if m, ok := value.(*OrderedMap); ok {
+		return m.Values()
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := strval(iter.Key().Interface())
+			keys = append(keys, key)
+			values[key] = iter.Value().Interface()
+		}
+		sort.Strings(keys)
+		children := make([]interface{}, 0, len(keys))
+		for _, key := range keys {
+			children = append(children, values[key])
+		}
+		return children
+	case reflect.Slice, reflect.Array:
+		children := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			children = append(children, v.Index(i).Interface())
+		}
+		return children
+	default:
+		return nil
+	}
+}
+
+func mapValue(value interface{}, key string) (interface{}, bool) {
+	if m, ok := value.(*OrderedMap); ok {
+		return m.Get(key), m.Has(key)
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Map {
+		return nil, false
+	}
+	iter := v.MapRange()
+	for iter.Next() {
+		if strval(iter.Key().Interface()) == key {
+			return iter.Value().Interface(), true
+		}
+	}
+	return nil, false
+}
+
+func listValue(value interface{}) (reflect.Value, bool) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return v, false
+	}
+	return v, true
+}
+
+type nameSelector struct {
+	names []string
+}
+
+func (s nameSelector) selectFrom(value interface{}, _ interface{}) []interface{} {
+	result := make([]interface{}, 0, len(s.names))
+	for _, name := range s.names {
+		if child, ok := mapValue(value, name); ok {
+			result = append(result, child)
+		}
+	}
+	return result
+}
+
+func (s nameSelector) definite() bool {
+	return len(s.names) == 1
+}
+
+type wildcardSelector struct{}
+
+func (wildcardSelector) selectFrom(value interface{}, _ interface{}) []interface{} {
+	return childValues(value)
+}
+
+func (wildcardSelector) definite() bool {
+	return false
+}
+
+type indexSelector struct {
+	indexes []int
+}
+
+func (s indexSelector) selectFrom(value interface{}, _ interface{}) []interface{} {
+	list, ok := listValue(value)
+	if !ok {
+		return nil
+	}
+	result := make([]interface{}, 0, len(s.indexes))
+	for _, index := range s.indexes {
+		if index < 0 {
+			index += list.Len()
+		}
+		if index >= 0 && index < list.Len() {
+			result = append(result, list.Index(index).Interface())
+		}
+	}
+	return result
+}
+
+func (s indexSelector) definite() bool {
+	return len(s.indexes) == 1
+}
+
+type sliceSelector struct {
+	start, end, step *int
+}
+
+func (s sliceSelector) selectFrom(value interface{}, _ interface{}) []interface{} {
+	list, ok := listValue(value)
+	if !ok {
+		return nil
+	}
+	length := list.Len()
+	step := 1
+	if s.step != nil {
+		step = *s.step
+	}
+	if step == 0 {
+		return nil
+	}
+	normalize := func(index *int, fallback int) int {
+		if index == nil {
+			return fallback
+		}
+		i := *index
+		if i < 0 {
+			i += length
+		}
+		if i < 0 {
+			return -1
+		}
+		if i > length {
+			return length
+		}
+		return i
+	}
+	result := make([]interface{}, 0)
+	if step > 0 {
+		start, end := normalize(s.start, 0), normalize(s.end, length)
+		if start < 0 {
+			start = 0
+		}
+		for i := start; i < end; i += step {
+			result = append(result, list.Index(i).Interface())
+		}
+	} else {
+		start, end := normalize(s.start, length-1), normalize(s.end, -1)
+		if start >= length {
+			start = length - 1
+		}
+		for i := start; i > end; i += step {
+			result = append(result, list.Index(i).Interface())
+		}
+	}
+	return result
+}
+
+func (sliceSelector) definite() bool {
+	return false
+}
+
+type filterSelector struct {
+	filter filterExpr
+}
+
+func (s filterSelector) selectFrom(value interface{}, root interface{}) []interface{} {
+	result := make([]interface{}, 0)
+	for _, child := range childValues(value) {
+		if truthy(s.filter.evaluate(child, root)) {
+			result = append(result, child)
+		}
+	}
+	return result
+}
+
+func (filterSelector) definite() bool {
+	return false
+}
+
+// filterExpr is an expression of a filter. It evaluates to a value, which is missing
+// (nil, false) if a path does not match.
+type filterExpr interface {
+	evaluate(current interface{}, root interface{}) filterValue
+}
+
+type filterValue struct {
+	value  interface{}
+	exists bool
+}
+
+func truthy(v filterValue) bool {
+	if !v.exists || v.value == nil {
+		return false
+	}
+	if b, ok := v.value.(bool); ok {
+		return b
+	}
+	return true
+}
+
+type literalExpr struct {
+	value interface{}
+}
+
+func (e literalExpr) evaluate(_ interface{}, _ interface{}) filterValue {
+	return filterValue{value: e.value, exists: true}
+}
+
+type pathExpr struct {
+	fromRoot bool
+	steps    []pathStep
+}
+
+func (e pathExpr) evaluate(current interface{}, root interface{}) filterValue {
+	start := current
+	if e.fromRoot {
+		start = root
+	}
+	values := evaluateSteps(e.steps, start, root)
+	if len(values) == 0 {
+		return filterValue{}
+	}
+	return filterValue{value: values[0], exists: true}
+}
+
+type notExpr struct {
+	expr filterExpr
+}
+
+func (e notExpr) evaluate(current interface{}, root interface{}) filterValue {
+	return filterValue{value: !truthy(e.expr.evaluate(current, root)), exists: true}
+}
+
+type binaryExpr struct {
+	operator    string
+	left, right filterExpr
+}
+
+func (e binaryExpr) evaluate(current interface{}, root interface{}) filterValue {
+	left := e.left.evaluate(current, root)
+	switch e.operator {
+	case "&&":
+		return filterValue{value: truthy(left) && truthy(e.right.evaluate(current, root)), exists: true}
+	case "||":
+		return filterValue{value: truthy(left) || truthy(e.right.evaluate(current, root)), exists: true}
+	}
+	right := e.right.evaluate(current, root)
+	if !left.exists || !right.exists {
+		return filterValue{value: e.operator == "!=" && left.exists != right.exists, exists: true}
+	}
+	return filterValue{value: compareValues(e.operator, left.value, right.value), exists: true}
+}
+
+func compareValues(operator string, left interface{}, right interface{}) bool {
+	switch operator {
+	case "==":
+		return jsonEqual(left, right)
+	case "!=":
+		return !jsonEqual(left, right)
+	case "=~":
+		re, err := regexp.Compile(strval(right))
+		return err == nil && re.MatchString(strval(left))
+	}
+	var cmp int
+	leftNum, leftErr := toNumber(left)
+	rightNum, rightErr := toNumber(right)
+	_, leftIsString := left.(string)
+	_, rightIsString := right.(string)
+	switch {
+	case !leftIsString && !rightIsString && leftErr == nil && rightErr == nil:
+		switch {
+		case leftNum < rightNum:
+			cmp = -1
+		case leftNum > rightNum:
+			cmp = 1
+		}
+	case leftIsString && rightIsString:
+		cmp = strings.Compare(left.(string), right.(string))
+	default:
+		return false
+	}
+	switch operator {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// pathParser is a recursive descent parser of JSONPath expressions.
+type pathParser struct {
+	text string
+	pos  int
+}
+
+func (p *pathParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("at position %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *pathParser) skipSpaces() {
+	for p.pos < len(p.text) && p.text[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *pathParser) consume(token string) bool {
+	if strings.HasPrefix(p.text[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+// parseSteps parses steps until the end of the text, or in filters until a character
+// which cannot be part of a path.
+func (p *pathParser) parseSteps(inFilter bool) ([]pathStep, error) {
+	steps := make([]pathStep, 0)
+	for p.pos < len(p.text) {
+		recursive := false
+		switch {
+		case p.consume(".."):
+			recursive = true
+			if p.pos < len(p.text) && p.text[p.pos] == '[' {
+				p.pos++
+				selector, err := p.parseBracket()
+				if err != nil {
+					return nil, err
+				}
+				steps = append(steps, pathStep{recursive: true, selector: selector})
+				continue
+			}
+		case p.consume("."):
+		case p.consume("["):
+			selector, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, pathStep{selector: selector})
+			continue
+		default:
+			if inFilter {
+				return steps, nil
+			}
+			return nil, p.errorf("expected '.' or '['")
+		}
+		if p.consume("*") {
+			steps = append(steps, pathStep{recursive: recursive, selector: wildcardSelector{}})
+			continue
+		}
+		name := p.parseName()
+		if name == "" {
+			return nil, p.errorf("expected a name")
+		}
+		steps = append(steps, pathStep{recursive: recursive, selector: nameSelector{names: []string{name}}})
+	}
+	return steps, nil
+}
+
+func (p *pathParser) parseName() string {
+	start := p.pos
+	for p.pos < len(p.text) {
+		r := rune(p.text[p.pos])
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r >= 0x80) {
+			break
+		}
+		p.pos++
+	}
+	return p.text[start:p.pos]
+}
+
+// parseBracket parses the content of brackets after the opening bracket.
+func (p *pathParser) parseBracket() (pathSelector, error) {
+	p.skipSpaces()
+	var selector pathSelector
+	switch {
+	case p.consume("*"):
+		selector = wildcardSelector{}
+	case p.consume("?"):
+		p.skipSpaces()
+		parenthesized := p.consume("(")
+		filter, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if parenthesized && !p.consume(")") {
+			return nil, p.errorf("expected ')'")
+		}
+		selector = filterSelector{filter: filter}
+	case p.pos < len(p.text) && (p.text[p.pos] == '\'' || p.text[p.pos] == '"'):
+		names := make([]string, 0)
+		for {
+			p.skipSpaces()
+			name, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, name)
+			p.skipSpaces()
+			if !p.consume(",") {
+				break
+			}
+		}
+		selector = nameSelector{names: names}
+	default:
+		var err error
+		if selector, err = p.parseIndexes(); err != nil {
+			return nil, err
+		}
+	}
+	p.skipSpaces()
+	if !p.consume("]") {
+		return nil, p.errorf("expected ']'")
+	}
+	return selector, nil
+}
+
+func (p *pathParser) parseIndexes() (pathSelector, error) {
+	parts := make([]*int, 0, 3)
+	indexes := make([]int, 0)
+	for {
+		p.skipSpaces()
+		start := p.pos
+		if p.pos < len(p.text) && p.text[p.pos] == '-' {
+			p.pos++
+		}
+		for p.pos < len(p.text) && p.text[p.pos] >= '0' && p.text[p.pos] <= '9' {
+			p.pos++
+		}
+		var number *int
+		if p.pos > start {
+			n, err := strconv.Atoi(p.text[start:p.pos])
+			if err != nil {
+				return nil, p.errorf("invalid index %s", p.text[start:p.pos])
+			}
+			number = &n
+		}
+		p.skipSpaces()
+		switch {
+		case p.consume(":"):
+			if len(indexes) > 0 {
+				return nil, p.errorf("a slice cannot be part of a union")
+			}
+			parts = append(parts, number)
+			if len(parts) > 2 {
+				return nil, p.errorf("slice must have the format [start:end:step]")
+			}
+		case p.consume(","):
+			if number == nil || len(parts) > 0 {
+				return nil, p.errorf("expected an index")
+			}
+			indexes = append(indexes, *number)
+		default:
+			if len(parts) > 0 {
+				parts = append(parts, number)
+				for len(parts) < 3 {
+					parts = append(parts, nil)
+				}
+				return sliceSelector{start: parts[0], end: parts[1], step: parts[2]}, nil
+			}
+			if number == nil {
+				return nil, p.errorf("expected an index, a name, '*' or '?'")
+			}
+			return indexSelector{indexes: append(indexes, *number)}, nil
+		}
+	}
+}
+
+func (p *pathParser) parseString() (string, error) {
+	if p.pos >= len(p.text) || (p.text[p.pos] != '\'' && p.text[p.pos] != '"') {
+		return "", p.errorf("expected a string")
+	}
+	quote := p.text[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.text) {
+		c := p.text[p.pos]
+		p.pos++
+		switch {
+		case c == '\\' && p.pos < len(p.text):
+			b.WriteByte(p.text[p.pos])
+			p.pos++
+		case c == quote:
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *pathParser) parseOr() (filterExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpaces()
+		if !p.consume("||") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{operator: "||", left: left, right: right}
+	}
+}
+
+func (p *pathParser) parseAnd() (filterExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpaces()
+		if !p.consume("&&") {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{operator: "&&", left: left, right: right}
+	}
+}
+
+func (p *pathParser) parseUnary() (filterExpr, error) {
+	p.skipSpaces()
+	if strings.HasPrefix(p.text[p.pos:], "!") && !strings.HasPrefix(p.text[p.pos:], "!=") {
+		p.pos++
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{expr: expr}, nil
+	}
+	if p.consume("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if !p.consume(")") {
+			return nil, p.errorf("expected ')'")
+		}
+		return expr, nil
+	}
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	for _, operator := range []string{"==", "!=", "<=", ">=", "=~", "<", ">"} {
+		if p.consume(operator) {
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return binaryExpr{operator: operator, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *pathParser) parseOperand() (filterExpr, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.text) {
+		return nil, p.errorf("expected an operand")
+	}
+	switch c := p.text[p.pos]; {
+	case c == '@' || c == '$':
+		p.pos++
+		steps, err := p.parseSteps(true)
+		if err != nil {
+			return nil, err
+		}
+		return pathExpr{fromRoot: c == '$', steps: steps}, nil
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return literalExpr{value: s}, nil
+	case c == '/':
+		end := strings.IndexByte(p.text[p.pos+1:], '/')
+		if end < 0 {
+			return nil, p.errorf("unterminated regular expression")
+		}
+		pattern := p.text[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return literalExpr{value: pattern}, nil
+	}
+	start := p.pos
+	for p.pos < len(p.text) && strings.IndexByte(" )]&|=!<>", p.text[p.pos]) < 0 {
+		p.pos++
+	}
+	word := p.text[start:p.pos]
+	switch word {
+	case "true":
+		return literalExpr{value: true}, nil
+	case "false":
+		return literalExpr{value: false}, nil
+	case "null":
+		return literalExpr{value: nil}, nil
+	}
+	number, err := strconv.ParseFloat(word, 64)
+	if err != nil {
+		return nil, p.errorf("invalid operand '%s'", word)
+	}
+	return literalExpr{value: number}, nil
+}
+
+// jsonPath returns all values which match a JSONPath expression.
+//
+//	{{ range jsonPath "$.servers[?(@.enabled)].host" .Values }}{{ . }}{{ end }}
+func jsonPath(expression string, data interface{}) ([]interface{}, error) {
+	path, err := CompileJSONPath(expression)
+	if err != nil {
+		return nil, err
+	}
+	return path.Find(data), nil
+}
+
+// query returns the value of a definite path (e.g. servers[0].host), or nil if it does not exist,
+// and the list of matching values for all other paths (e.g. servers[*].host).
+//
+//	bootstrap.servers={{ query "kafka.brokers[*].address" .Values | join "," }}
+func query(expression string, data interface{}) (interface{}, error) {
+	path, err := CompileJSONPath(expression)
+	if err != nil {
+		return nil, err
+	}
+	return path.Query(data), nil
+}
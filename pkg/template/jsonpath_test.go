@@ -0,0 +1,161 @@
+package template
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func jsonPathFixture() map[string]interface{} {
+	return map[string]interface{}{
+		"cluster": "kafka",
+		"brokers": []interface{}{
+			map[string]interface{}{"id": 0, "host": "kafka-0", "port": 9092, "enabled": true},
+			map[string]interface{}{"id": 1, "host": "kafka-1", "port": 9093, "enabled": false},
+			map[string]interface{}{"id": 2, "host": "kafka-2", "port": 19092},
+		},
+		"numbers": []interface{}{0, 1, 2, 3, 4, 5},
+		"config": map[string]interface{}{
+			"log.dirs": "/var/lib/kafka",
+			"replicas": 3,
+		},
+		"minPort": 9093,
+	}
+}
+
+func TestJSONPathFind(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   []interface{}
+	}{
+		// selection
+		{"$", []interface{}{jsonPathFixture()}},
+		{"$.cluster", []interface{}{"kafka"}},
+		{"cluster", []interface{}{"kafka"}},
+		{".cluster", []interface{}{"kafka"}},
+		{"$['cluster']", []interface{}{"kafka"}},
+		{`$["config"]["log.dirs"]`, []interface{}{"/var/lib/kafka"}},
+		{"$.config['log.dirs','replicas']", []interface{}{"/var/lib/kafka", 3}},
+		{"$.brokers[0].host", []interface{}{"kafka-0"}},
+		{"$.brokers[-1].host", []interface{}{"kafka-2"}},
+		{"$.brokers[0,2].id", []interface{}{0, 2}},
+		{"$.brokers[5].host", []interface{}{}},
+		{"$.missing.host", []interface{}{}},
+		{"$.cluster.host", []interface{}{}},
+		// wildcard and recursive descent
+		{"$.brokers[*].host", []interface{}{"kafka-0", "kafka-1", "kafka-2"}},
+		{"$.brokers.*.id", []interface{}{0, 1, 2}},
+		{"$.config.*", []interface{}{"/var/lib/kafka", 3}},
+		{"$..port", []interface{}{9092, 9093, 19092}},
+		{"$..['host']", []interface{}{"kafka-0", "kafka-1", "kafka-2"}},
+		{"$.brokers[0]..*", []interface{}{true, "kafka-0", 0, 9092}},
+		// slices
+		{"$.numbers[1:3]", []interface{}{1, 2}},
+		{"$.numbers[:2]", []interface{}{0, 1}},
+		{"$.numbers[4:]", []interface{}{4, 5}},
+		{"$.numbers[-2:]", []interface{}{4, 5}},
+		{"$.numbers[::2]", []interface{}{0, 2, 4}},
+		{"$.numbers[::-2]", []interface{}{5, 3, 1}},
+		{"$.numbers[3:1:-1]", []interface{}{3, 2}},
+		{"$.numbers[10:]", []interface{}{}},
+		{"$.numbers[::0]", []interface{}{}},
+		// filters
+		{"$.brokers[?(@.enabled)].host", []interface{}{"kafka-0"}},
+		{"$.brokers[?(!@.enabled)].host", []interface{}{"kafka-1", "kafka-2"}},
+		{"$.brokers[?(@.port > 9092)].id", []interface{}{1, 2}},
+		{"$.brokers[?(@.port >= $.minPort)].id", []interface{}{1, 2}},
+		{"$.brokers[?(@.port < 9093 || @.id == 2)].id", []interface{}{0, 2}},
+		{"$.brokers[?(@.port > 9000 && @.port <= 9093)].id", []interface{}{0, 1}},
+		{"$.brokers[?(@.host == 'kafka-1')].port", []interface{}{9093}},
+		{`$.brokers[?(@.host != "kafka-1")].id`, []interface{}{0, 2}},
+		{"$.brokers[?(@.enabled != true)].id", []interface{}{1, 2}},
+		{"$.brokers[?(@.host =~ /kafka-[12]/)].id", []interface{}{1, 2}},
+		{"$.brokers[?((@.id == 0 || @.id == 1) && @.enabled)].id", []interface{}{0}},
+		{"$.brokers[?(@.host > 'kafka-0')].id", []interface{}{1, 2}},
+		{"$.brokers[?(@.host > 1)].id", []interface{}{}},
+		{"$.numbers[?(@ >= 4)]", []interface{}{4, 5}},
+		{"$.brokers[? @.id == 1].host", []interface{}{"kafka-1"}},
+	}
+	for _, test := range tests {
+		path, err := CompileJSONPath(test.expression)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.expression, err)
+			continue
+		}
+		if values := path.Find(jsonPathFixture()); !reflect.DeepEqual(values, test.expected) {
+			t.Errorf("%s: expected %v, but got %v", test.expression, test.expected, values)
+		}
+	}
+}
+
+func TestJSONPathQuery(t *testing.T) {
+	tests := []struct {
+		expression string
+		definite   bool
+		expected   interface{}
+	}{
+		{"$.brokers[1].host", true, "kafka-1"},
+		{"$.brokers[3].host", true, nil},
+		{"$.config['replicas']", true, 3},
+		{"$.brokers[*].id", false, []interface{}{0, 1, 2}},
+		{"$.brokers[0,1].id", false, []interface{}{0, 1}},
+		{"$.numbers[0:1]", false, []interface{}{0}},
+		{"$..cluster", false, []interface{}{"kafka"}},
+		{"$.brokers[?(@.id == 5)]", false, []interface{}{}},
+	}
+	for _, test := range tests {
+		path, err := CompileJSONPath(test.expression)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.expression, err)
+			continue
+		}
+		if path.Definite() != test.definite {
+			t.Errorf("%s: expected definite to be %v", test.expression, test.definite)
+		}
+		if value := path.Query(jsonPathFixture()); !reflect.DeepEqual(value, test.expected) {
+			t.Errorf("%s: expected %v, but got %v", test.expression, test.expected, value)
+		}
+	}
+}
+
+func TestJSONPathErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		err        string
+	}{
+		{"$.", "expected a name"},
+		{"$.brokers[0", "expected ']'"},
+		{"$.brokers[]", "expected an index, a name, '*' or '?'"},
+		{"$.brokers[a]", "expected an index, a name, '*' or '?'"},
+		{"$.numbers[1:2:3:4]", "slice must have the format [start:end:step]"},
+		{"$.numbers[1,2:3]", "a slice cannot be part of a union"},
+		{"$.numbers[,1]", "expected an index"},
+		{"$['cluster", "unterminated string"},
+		{"$.brokers[?(@.id == 1]", "expected ')'"},
+		{"$.brokers[?(@.id == )]", "invalid operand ''"},
+		{"$.brokers[?(@.id == one)]", "invalid operand 'one'"},
+		{"$.brokers[?(@.host =~ /kafka)]", "unterminated regular expression"},
+		{"$.brokers[?(@.id ==", "expected an operand"},
+		{"$cluster", "expected '.' or '['"},
+	}
+	for _, test := range tests {
+		_, err := CompileJSONPath(test.expression)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error containing %q, but got: %v", test.expression, test.err, err)
+		}
+	}
+}
+
+func TestJSONPathFunctions(t *testing.T) {
+	values, err := jsonPath("$.brokers[?(@.enabled)].host", jsonPathFixture())
+	if err != nil || !reflect.DeepEqual(values, []interface{}{"kafka-0"}) {
+		t.Errorf("expected [kafka-0], but got %v (%v)", values, err)
+	}
+	value, err := query("brokers[2].port", jsonPathFixture())
+	if err != nil || value != 19092 {
+		t.Errorf("expected 19092, but got %v (%v)", value, err)
+	}
+	if _, err := query("brokers[", jsonPathFixture()); err == nil || !strings.HasPrefix(err.Error(), "invalid JSONPath brokers[:") {
+		t.Errorf("expected an invalid JSONPath error, but got: %v", err)
+	}
+}
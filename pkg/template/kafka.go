@@ -0,0 +1,219 @@
+package template
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// defaultKafkaProtocolMap is used by Kafka if listener.security.protocol.map is not set.
+var defaultKafkaProtocolMap = map[string]string{
+	"PLAINTEXT":      "PLAINTEXT",
+	"SSL":            "SSL",
+	"SASL_PLAINTEXT": "SASL_PLAINTEXT",
+	"SASL_SSL":       "SASL_SSL",
+}
+
+// KafkaListener is an entry of listeners or advertised.listeners with its security protocol.
+// In templates it is rendered in the format of Kafka, e.g. INTERNAL://kafka:9092.
+type KafkaListener struct {
+	Name     string
+	Protocol string
+	Host     string
+	Port     int
+}
+
+func (l KafkaListener) String() string {
+	return Endpoint{Scheme: l.Name, Host: l.Host, Port: l.Port}.String()
+}
+
+// kafkaProtocolMap parses listener.security.protocol.map, e.g. INTERNAL:PLAINTEXT,EXTERNAL:SSL.
+// Listener names are returned in upper case. If the text is empty, the default map of Kafka is returned.
+func kafkaProtocolMap(text string) (map[string]interface{}, error) {
+	protocolMap := make(map[string]interface{})
+	if strings.TrimSpace(text) == "" {
+		for name, protocol := range defaultKafkaProtocolMap {
+			protocolMap[name] = protocol
+		}
+		return protocolMap, nil
+	}
+	for _, entry := range strings.Split(text, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, protocol, ok := strings.Cut(entry, ":")
+		name, protocol = strings.ToUpper(strings.TrimSpace(name)), strings.ToUpper(strings.TrimSpace(protocol))
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid entry '%s' in security protocol map, expected format is NAME:PROTOCOL", entry)
+		}
+		if err := validateKafkaProtocol(name, protocol); err != nil {
+			return nil, err
+		}
+		if _, duplicate := protocolMap[name]; duplicate {
+			return nil, fmt.Errorf("listener %s is defined more than once in security protocol map", name)
+		}
+		protocolMap[name] = protocol
+	}
+	return protocolMap, nil
+}
+
+func validateKafkaProtocol(name string, protocol string) error {
+	if _, supported := defaultKafkaProtocolMap[protocol]; !supported {
+		return fmt.Errorf("listener %s has unknown security protocol %s, supported are [PLAINTEXT, SSL, SASL_PLAINTEXT, SASL_SSL]", name, protocol)
+	}
+	return nil
+}
+
+// kafkaListeners parses listeners or advertised.listeners, e.g. INTERNAL://:9092,EXTERNAL://kafka:9093.
+// The security protocol of each listener is taken from the optional security protocol map.
+//
+//	{{ range kafkaListeners (env "KAFKA_LISTENERS") (env "KAFKA_LISTENER_SECURITY_PROTOCOL_MAP") }}
+//	{{ .Name }} {{ .Protocol }} {{ .Host }} {{ .Port }}
+//	{{ end }}
+func kafkaListeners(text string, protocolMap ...interface{}) ([]KafkaListener, error) {
+	protocols, err := kafkaProtocolMapOf(protocolMap)
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := parseEndpoints(text)
+	if err != nil {
+		return nil, err
+	}
+	listeners := make([]KafkaListener, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		name := strings.ToUpper(endpoint.Scheme)
+		if name == "" {
+			return nil, fmt.Errorf("listener %s has no name, expected format is NAME://host:port", endpoint)
+		}
+		protocol, ok := protocols[name]
+		if !ok {
+			return nil, fmt.Errorf("listener %s is not defined in security protocol map", name)
+		}
+		listeners = append(listeners, KafkaListener{Name: name, Protocol: strval(protocol), Host: endpoint.Host, Port: endpoint.Port})
+	}
+	return listeners, nil
+}
+
+// kafkaProtocolMapOf accepts either nothing (default map), the text of the security protocol map
+// or an already parsed map. The protocols of a map must be known security protocols.
+func kafkaProtocolMapOf(protocolMap []interface{}) (map[string]interface{}, error) {
+	if len(protocolMap) == 0 {
+		return kafkaProtocolMap("")
+	}
+	if len(protocolMap) > 1 {
+		return nil, fmt.Errorf("expected at most one security protocol map, but got %d arguments", len(protocolMap))
+	}
+	if reflect.ValueOf(protocolMap[0]).Kind() == reflect.Map {
+		protocols := make(map[string]interface{})
+		iter := reflect.ValueOf(protocolMap[0]).MapRange()
+		for iter.Next() {
+			name, protocol := strings.ToUpper(strval(iter.Key().Interface())), strings.ToUpper(strval(iter.Value().Interface()))
+			if err := validateKafkaProtocol(name, protocol); err != nil {
+				return nil, err
+			}
+			protocols[name] = protocol
+		}
+		return protocols, nil
+	}
+	return kafkaProtocolMap(strval(protocolMap[0]))
+}
+
+// kafkaValidateListeners checks that listeners, advertised listeners and security protocol map
+// are consistent. Every listener must have a security protocol, names and addresses of listeners must
+// be unique, and every advertised listener must be a listener. Empty and wildcard hosts bind all
+// addresses, so they conflict with every other listener on the same port. It renders to an empty string.
+//
+//	{{ kafkaValidateListeners (env "KAFKA_LISTENERS") (env "KAFKA_ADVERTISED_LISTENERS") (env "KAFKA_LISTENER_SECURITY_PROTOCOL_MAP") }}
+func kafkaValidateListeners(listenersText string, advertisedText string, protocolMapText string) (string, error) {
+	listeners, err := kafkaListeners(listenersText, protocolMapText)
+	if err != nil {
+		return "", fmt.Errorf("invalid listeners: %v", err)
+	}
+	names := make(map[string]bool)
+	for i, listener := range listeners {
+		if names[listener.Name] {
+			return "", fmt.Errorf("invalid listeners: listener %s is defined more than once", listener.Name)
+		}
+		names[listener.Name] = true
+		if listener.Port == 0 {
+			continue
+		}
+		for _, other := range listeners[:i] {
+			if other.Port == listener.Port && (isWildcardHost(other.Host) || isWildcardHost(listener.Host) || other.Host == listener.Host) {
+				return "", fmt.Errorf("invalid listeners: listeners %s (%s) and %s (%s) bind the same address",
+					other.Name, Endpoint{Host: other.Host, Port: other.Port}.HostPort(), listener.Name, Endpoint{Host: listener.Host, Port: listener.Port}.HostPort())
+			}
+		}
+	}
+	if strings.TrimSpace(advertisedText) == "" {
+		return "", nil
+	}
+	advertised, err := kafkaListeners(advertisedText, protocolMapText)
+	if err != nil {
+		return "", fmt.Errorf("invalid advertised listeners: %v", err)
+	}
+	advertisedNames := make(map[string]bool)
+	for _, listener := range advertised {
+		if !names[listener.Name] {
+			return "", fmt.Errorf("invalid advertised listeners: listener %s is not defined in listeners", listener.Name)
+		}
+		if advertisedNames[listener.Name] {
+			return "", fmt.Errorf("invalid advertised listeners: listener %s is defined more than once", listener.Name)
+		}
+		advertisedNames[listener.Name] = true
+		if isWildcardHost(listener.Host) {
+			return "", fmt.Errorf("invalid advertised listeners: listener %s must not advertise the wildcard address %s", listener.Name, listener.Host)
+		}
+	}
+	return "", nil
+}
+
+// kafkaAdvertisedListeners replaces empty and wildcard hosts (0.0.0.0 and ::) of listeners with the
+// given host and returns them as comma separated list. The host can be anything which renders to
+// a host name or IP address, e.g. the result of defaultIpAddress.
+//
+//	advertised.listeners={{ kafkaAdvertisedListeners (env "KAFKA_LISTENERS") (defaultIpAddress "ipv4") }}
+func kafkaAdvertisedListeners(listenersText string, host interface{}) (string, error) {
+	hostText := strings.Trim(strval(host), "[]")
+	if hostText == "" {
+		return "", fmt.Errorf("host must not be empty")
+	}
+	endpoints, err := parseEndpoints(listenersText)
+	if err != nil {
+		return "", err
+	}
+	advertised := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if isWildcardHost(endpoint.Host) {
+			endpoint.Host = hostText
+		}
+		advertised = append(advertised, endpoint.String())
+	}
+	return strings.Join(advertised, ","), nil
+}
+
+func isWildcardHost(host string) bool {
+	return host == "" || host == "0.0.0.0" || host == "::"
+}
+
+// kafkaListenerPrefix returns the prefix of listener-specific properties. The mechanism is optional
+// and only required for SASL properties.
+//
+//	{{ kafkaListenerPrefix "INTERNAL" "PLAIN" }}sasl.jaas.config -> listener.name.internal.plain.sasl.jaas.config
+func kafkaListenerPrefix(listenerName string, mechanism ...string) string {
+	prefix := "listener.name." + strings.ToLower(listenerName) + "."
+	for _, m := range mechanism {
+		if m != "" {
+			prefix += strings.ToLower(m) + "."
+		}
+	}
+	return prefix
+}
+
+// kafkaListenerProps prefixes all keys of the map with the prefix of listener-specific properties.
+// The mechanism can be empty for properties which are not SASL specific.
+//
+//	{{ kafkaListenerProps "EXTERNAL" "" (envToProp "KAFKA_EXTERNAL_" "") | toProperties }}
+func kafkaListenerProps(listenerName string, mechanism string, props interface{}) map[string]interface{} {
+	return replaceKeyPrefix("", kafkaListenerPrefix(listenerName, mechanism), props)
+}
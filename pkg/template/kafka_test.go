@@ -0,0 +1,49 @@
+package template
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKafkaValidateListeners(t *testing.T) {
+	tests := []struct {
+		listeners   string
+		advertised  string
+		protocolMap string
+		err         string
+	}{
+		{"PLAINTEXT://:9092,SSL://:9093", "PLAINTEXT://kafka:9092,SSL://kafka:9093", "", ""},
+		{"PLAINTEXT://a:9092,SSL://b:9092", "", "", ""},
+		{"PLAINTEXT://:0,SSL://:0", "", "", ""},
+		{"PLAINTEXT://:9092,SSL://0.0.0.0:9092", "", "", "listeners PLAINTEXT (:9092) and SSL (0.0.0.0:9092) bind the same address"},
+		{"PLAINTEXT://[::]:9092,SSL://kafka:9092", "", "", "bind the same address"},
+		{"PLAINTEXT://kafka:9092,SSL://kafka:9092", "", "", "bind the same address"},
+		{"PLAINTEXT://:9092,PLAINTEXT://:9093", "", "", "listener PLAINTEXT is defined more than once"},
+		{"INTERNAL://:9092", "", "INTERNAL:TLS", "unknown security protocol TLS"},
+		{"INTERNAL://:9092", "", "", "listener INTERNAL is not defined in security protocol map"},
+		{"PLAINTEXT://:9092", "SSL://kafka:9093", "", "listener SSL is not defined in listeners"},
+		{"PLAINTEXT://:9092", "PLAINTEXT://0.0.0.0:9092", "", "must not advertise the wildcard address"},
+	}
+	for _, test := range tests {
+		_, err := kafkaValidateListeners(test.listeners, test.advertised, test.protocolMap)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: expected no error, but got: %v", test.listeners, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: expected error containing %q, but got: %v", test.listeners, test.err, err)
+		}
+	}
+}
+
+func TestKafkaListenersProtocolMapDict(t *testing.T) {
+	listeners, err := kafkaListeners("internal://:9092", map[string]interface{}{"internal": "sasl_ssl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != 1 || listeners[0].Name != "INTERNAL" || listeners[0].Protocol != "SASL_SSL" {
+		t.Errorf("unexpected listeners: %+v", listeners)
+	}
+	if _, err := kafkaListeners("INTERNAL://:9092", map[string]interface{}{"INTERNAL": "TLS"}); err == nil || !strings.Contains(err.Error(), "unknown security protocol TLS") {
+		t.Errorf("expected an unknown security protocol error, but got: %v", err)
+	}
+}
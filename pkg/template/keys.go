@@ -0,0 +1,241 @@
+package template
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// keyMatcher matches keys or values against a list of patterns. A pattern is a regular expression,
+// if it is enclosed in slashes (e.g. /^KAFKA_.*_OPTS$/), otherwise it is an exact name or a glob
+// (e.g. KAFKA_*_OPTS) with the wildcards '*', '?' and '[...]'.
+type keyMatcher struct {
+	names   []string
+	globs   []string
+	regexps []*regexp.Regexp
+}
+
+func newKeyMatcher(patterns interface{}) (*keyMatcher, error) {
+	matcher := &keyMatcher{}
+	if patterns == nil {
+		return matcher, nil
+	}
+	for _, pattern := range toFlatListOfStrings(patterns) {
+		switch {
+		case len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/"):
+			re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression %s: %v", pattern, err)
+			}
+			matcher.regexps = append(matcher.regexps, re)
+		case strings.ContainsAny(pattern, "*?["):
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid glob %s: %v", pattern, err)
+			}
+			matcher.globs = append(matcher.globs, pattern)
+		default:
+			matcher.names = append(matcher.names, pattern)
+		}
+	}
+	return matcher, nil
+}
+
+func mustKeyMatcher(patterns interface{}) *keyMatcher {
+	matcher, err := newKeyMatcher(patterns)
+	if err != nil {
+		panic(err)
+	}
+	return matcher
+}
+
+func (m *keyMatcher) matches(text string) bool {
+	if contains(m.names, text) {
+		return true
+	}
+	for _, glob := range m.globs {
+		if matched, _ := path.Match(glob, text); matched {
+			return true
+		}
+	}
+	for _, re := range m.regexps {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+func filterKeys(matcher *keyMatcher, keep bool, sourceMap interface{}) map[string]interface{} {
+	sourceMapVal := mapValueOf(sourceMap)
+	switch sourceMapVal.Kind() {
+	case reflect.Map:
+		resultMap := make(map[string]interface{})
+		iter := sourceMapVal.MapRange()
+		for iter.Next() {
+			key := strval(iter.Key().Interface())
+			if matcher.matches(key) == keep {
+				resultMap[key] = iter.Value().Interface()
+			}
+		}
+		return resultMap
+	default:
+		panic(fmt.Errorf("must be a map but was %T", sourceMap))
+	}
+}
+
+// includeKeys returns the entries of a map whose keys match one of the patterns
+// (names, globs like KAFKA_*_OPTS or regular expressions like /^KAFKA_.*$/).
+func includeKeys(include interface{}, sourceMap interface{}) map[string]interface{} {
+	return filterKeys(mustKeyMatcher(include), true, sourceMap)
+}
+
+// filterByValue returns the entries of a map whose values match one of the patterns
+// (names, globs or regular expressions enclosed in slashes).
+func filterByValue(patterns interface{}, sourceMap interface{}) map[string]interface{} {
+	matcher := mustKeyMatcher(patterns)
+	sourceMapVal := mapValueOf(sourceMap)
+	switch sourceMapVal.Kind() {
+	case reflect.Map:
+		resultMap := make(map[string]interface{})
+		iter := sourceMapVal.MapRange()
+		for iter.Next() {
+			value := iter.Value().Interface()
+			if matcher.matches(strval(value)) {
+				resultMap[strval(iter.Key().Interface())] = value
+			}
+		}
+		return resultMap
+	default:
+		panic(fmt.Errorf("must be a map but was %T", sourceMap))
+	}
+}
+
+// renameKeys renames the keys of a map with a dict of old to new names. Other keys are kept.
+//
+//	{{ envToMap "KAFKA_" | renameKeys (dict "KAFKA_ZOOKEEPER_CLIENT_CNXN_SOCKET" "zookeeper.clientCnxnSocket") }}
+func renameKeys(mapping interface{}, sourceMap interface{}) (map[string]interface{}, error) {
+	names, isMap := asStringMap(mapping)
+	if !isMap {
+		return nil, fmt.Errorf("mapping must be a dict but was %T", mapping)
+	}
+	return transformMap(sourceMap, func(key string, value interface{}) (string, interface{}, error) {
+		if name, renamed := names[key]; renamed {
+			return strval(name), value, nil
+		}
+		return key, value, nil
+	})
+}
+
+// transformMap creates a new map with the transformed entries of a map. It fails, if two keys
+// are transformed into the same key.
+func transformMap(sourceMap interface{}, transform func(key string, value interface{}) (string, interface{}, error)) (map[string]interface{}, error) {
+	sourceMapVal := mapValueOf(sourceMap)
+	if sourceMapVal.Kind() != reflect.Map {
+		return nil, fmt.Errorf("must be a map but was %T", sourceMap)
+	}
+	resultMap := make(map[string]interface{})
+	sources := make(map[string]string)
+	iter := sourceMapVal.MapRange()
+	for iter.Next() {
+		key := strval(iter.Key().Interface())
+		newKey, newValue, err := transform(key, iter.Value().Interface())
+		if err != nil {
+			return nil, err
+		}
+		if source, exists := sources[newKey]; exists {
+			return nil, fmt.Errorf("keys %s and %s both become %s", source, key, newKey)
+		}
+		sources[newKey] = key
+		resultMap[newKey] = newValue
+	}
+	return resultMap, nil
+}
+
+// mappingFuncs returns mapKeys and mapValues, which can apply the named templates of t.
+// Like tpl, they are bound to the template which is rendered.
+func mappingFuncs(t *template.Template) template.FuncMap {
+	return template.FuncMap{
+		"mapKeys": func(name string, sourceMap interface{}) (map[string]interface{}, error) {
+			return mapKeys(t, name, sourceMap)
+		},
+		"mapValues": func(name string, sourceMap interface{}) (map[string]interface{}, error) {
+			return mapValues(t, name, sourceMap)
+		},
+	}
+}
+
+// mapKeys applies a named template or a function with a single argument to every key of a map.
+//
+//	{{ define "propKey" }}{{ . | trimPrefix "KAFKA_" | lower | replace "_" "." }}{{ end }}
+//	{{ envToMap "KAFKA_" | mapKeys "propKey" }}
+func mapKeys(t *template.Template, name string, sourceMap interface{}) (map[string]interface{}, error) {
+	apply, err := entryMapper(t, name)
+	if err != nil {
+		return nil, err
+	}
+	return transformMap(sourceMap, func(key string, value interface{}) (string, interface{}, error) {
+		newKey, err := apply(key)
+		return strval(newKey), value, err
+	})
+}
+
+// mapValues applies a named template or a function with a single argument to every value of a map.
+//
+//	{{ envToProp "KAFKA_" "" | mapValues "trim" }}
+func mapValues(t *template.Template, name string, sourceMap interface{}) (map[string]interface{}, error) {
+	apply, err := entryMapper(t, name)
+	if err != nil {
+		return nil, err
+	}
+	return transformMap(sourceMap, func(key string, value interface{}) (string, interface{}, error) {
+		newValue, err := apply(value)
+		return key, newValue, err
+	})
+}
+
+// entryMapper returns a function, which executes the named template with the argument as dot and
+// returns the trimmed output, or which calls the function with the name. Arguments are converted
+// to strings, if the function requires a string.
+func entryMapper(t *template.Template, name string) (func(arg interface{}) (interface{}, error), error) {
+	if t != nil && t.Lookup(name) != nil {
+		return func(arg interface{}) (interface{}, error) {
+			var buf strings.Builder
+			if err := t.ExecuteTemplate(&buf, name, arg); err != nil {
+				return nil, err
+			}
+			return strings.TrimSpace(buf.String()), nil
+		}, nil
+	}
+	fn, exists := funcMap()[name]
+	if !exists {
+		return nil, fmt.Errorf("%s is neither a template nor a function", name)
+	}
+	fnVal := reflect.ValueOf(fn)
+	fnType := fnVal.Type()
+	if fnType.NumIn() != 1 || fnType.IsVariadic() || fnType.NumOut() < 1 || fnType.NumOut() > 2 {
+		return nil, fmt.Errorf("function %s must have exactly one argument", name)
+	}
+	in := fnType.In(0)
+	return func(arg interface{}) (interface{}, error) {
+		argVal := reflect.ValueOf(arg)
+		switch {
+		case !argVal.IsValid():
+			argVal = reflect.Zero(in)
+		case argVal.Type().AssignableTo(in):
+		case in.Kind() == reflect.String:
+			argVal = reflect.ValueOf(strval(arg)).Convert(in)
+		default:
+			return nil, fmt.Errorf("function %s cannot be applied to %T", name, arg)
+		}
+		results := fnVal.Call([]reflect.Value{argVal})
+		if len(results) == 2 {
+			if err, _ := results[1].Interface().(error); err != nil {
+				return nil, err
+			}
+		}
+		return results[0].Interface(), nil
+	}, nil
+}
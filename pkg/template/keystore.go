@@ -0,0 +1,306 @@
+package template
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const (
+	KeystorePKCS12 = "pkcs12"
+	KeystoreJKS    = "jks"
+)
+
+// KeystoreTypes are the supported types of Java keystores.
+var KeystoreTypes = []string{KeystorePKCS12, KeystoreJKS}
+
+// KeystoreTypeOf returns the keystore type of a file by its extension. Files with unknown extensions,
+// like .p12 and .pfx, are PKCS #12 keystores, which is also the default of Java.
+func KeystoreTypeOf(filename string) string {
+	if strings.HasSuffix(strings.ToLower(filename), ".jks") {
+		return KeystoreJKS
+	}
+	return KeystorePKCS12
+}
+
+// NewKeystore creates a Java keystore with the private key, which belongs to the first certificate.
+// The other certificates are its chain. PKCS #12 keystores are encrypted with AES-256 and require
+// Java 8u301 or 11.0.12 and newer. The key entry of a PKCS #12 keystore has no alias, which Java
+// names 1, so an alias is only supported for JKS keystores, where it defaults to mykey.
+func NewKeystore(storeType string, alias string, key crypto.Signer, certs []*x509.Certificate, password string) ([]byte, error) {
+	if storeType == KeystorePKCS12 && alias != "" {
+		return nil, fmt.Errorf("alias is not supported for pkcs12 keystores, whose key entry is named 1 by Java, use type jks instead")
+	}
+	if alias == "" {
+		alias = "mykey"
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("keystore requires a certificate")
+	}
+	if !KeyMatchesCertificate(key, certs[0]) {
+		return nil, fmt.Errorf("key does not belong to %s", certs[0].Subject.String())
+	}
+	switch storeType {
+	case KeystorePKCS12:
+		return pkcs12.Modern.Encode(key, certs[0], certs[1:], password)
+	case KeystoreJKS:
+		if password == "" {
+			return nil, fmt.Errorf("jks keystores require a password")
+		}
+		encoded, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		protected, err := jksProtectKey(encoded, password)
+		if err != nil {
+			return nil, err
+		}
+		var store jksWriter
+		store.privateKeyEntry(strings.ToLower(alias), protected, certs)
+		return store.finish(password), nil
+	default:
+		return nil, fmt.Errorf("unknown keystore type %s, supported are %v", storeType, KeystoreTypes)
+	}
+}
+
+// NewTruststore creates a Java keystore with trusted certificates. The alias of a single certificate
+// is the alias, multiple certificates get the alias with their index as suffix, e.g. caroot-0.
+func NewTruststore(storeType string, alias string, certs []*x509.Certificate, password string) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("truststore requires at least one certificate")
+	}
+	aliases := make([]string, len(certs))
+	for i := range certs {
+		aliases[i] = strings.ToLower(alias)
+		if len(certs) > 1 {
+			aliases[i] = fmt.Sprintf("%s-%d", aliases[i], i)
+		}
+	}
+	switch storeType {
+	case KeystorePKCS12:
+		entries := make([]pkcs12.TrustStoreEntry, len(certs))
+		for i, cert := range certs {
+			entries[i] = pkcs12.TrustStoreEntry{Cert: cert, FriendlyName: aliases[i]}
+		}
+		return pkcs12.Modern.EncodeTrustStoreEntries(entries, password)
+	case KeystoreJKS:
+		if password == "" {
+			return nil, fmt.Errorf("jks keystores require a password")
+		}
+		var store jksWriter
+		for i, cert := range certs {
+			store.trustedCertEntry(aliases[i], cert)
+		}
+		return store.finish(password), nil
+	default:
+		return nil, fmt.Errorf("unknown keystore type %s, supported are %v", storeType, KeystoreTypes)
+	}
+}
+
+// jksWriter writes the proprietary JKS format of Java, which is still required by some tools.
+type jksWriter struct {
+	entries bytes.Buffer
+	count   uint32
+}
+
+func (w *jksWriter) entryHeader(tag uint32, alias string) {
+	w.count++
+	_ = binary.Write(&w.entries, binary.BigEndian, tag)
+	w.writeUTF(alias)
+	_ = binary.Write(&w.entries, binary.BigEndian, time.Now().UnixMilli())
+}
+
+func (w *jksWriter) writeUTF(text string) {
+	_ = binary.Write(&w.entries, binary.BigEndian, uint16(len(text)))
+	w.entries.WriteString(text)
+}
+
+func (w *jksWriter) writeCert(cert *x509.Certificate) {
+	w.writeUTF("X.509")
+	_ = binary.Write(&w.entries, binary.BigEndian, uint32(len(cert.Raw)))
+	w.entries.Write(cert.Raw)
+}
+
+func (w *jksWriter) privateKeyEntry(alias string, protectedKey []byte, chain []*x509.Certificate) {
+	w.entryHeader(1, alias)
+	_ = binary.Write(&w.entries, binary.BigEndian, uint32(len(protectedKey)))
+	w.entries.Write(protectedKey)
+	_ = binary.Write(&w.entries, binary.BigEndian, uint32(len(chain)))
+	for _, cert := range chain {
+		w.writeCert(cert)
+	}
+}
+
+func (w *jksWriter) trustedCertEntry(alias string, cert *x509.Certificate) {
+	w.entryHeader(2, alias)
+	w.writeCert(cert)
+}
+
+// finish returns the keystore with the header and the integrity digest over the password, the
+// phrase "Mighty Aphrodite" and the content.
+func (w *jksWriter) finish(password string) []byte {
+	var store bytes.Buffer
+	_ = binary.Write(&store, binary.BigEndian, uint32(0xFEEDFEED))
+	_ = binary.Write(&store, binary.BigEndian, uint32(2))
+	_ = binary.Write(&store, binary.BigEndian, w.count)
+	store.Write(w.entries.Bytes())
+	digest := sha1.New()
+	digest.Write(jksPassword(password))
+	digest.Write([]byte("Mighty Aphrodite"))
+	digest.Write(store.Bytes())
+	return digest.Sum(store.Bytes())
+}
+
+// jksPassword encodes a password like Java does for JKS, with two bytes per char.
+func jksPassword(password string) []byte {
+	chars := utf16.Encode([]rune(password))
+	encoded := make([]byte, 0, 2*len(chars))
+	for _, c := range chars {
+		encoded = append(encoded, byte(c>>8), byte(c))
+	}
+	return encoded
+}
+
+var oidJksKeyProtector = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+
+type jksEncryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+// jksProtectKey encrypts a PKCS #8 key with the key protector of Java, which xors the key with a
+// chain of SHA-1 digests of the password and a random salt.
+func jksProtectKey(key []byte, password string) ([]byte, error) {
+	passwordBytes := jksPassword(password)
+	salt := make([]byte, sha1.Size)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	protected := append(make([]byte, 0, 2*sha1.Size+len(key)), salt...)
+	digest := salt
+	for offset := 0; offset < len(key); offset += sha1.Size {
+		sum := sha1.Sum(append(append([]byte{}, passwordBytes...), digest...))
+		digest = sum[:]
+		for i := 0; i < sha1.Size && offset+i < len(key); i++ {
+			protected = append(protected, key[offset+i]^digest[i])
+		}
+	}
+	checksum := sha1.Sum(append(append([]byte{}, passwordBytes...), key...))
+	protected = append(protected, checksum[:]...)
+	return asn1.Marshal(jksEncryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidJksKeyProtector, Parameters: asn1.NullRawValue},
+		EncryptedData: protected,
+	})
+}
+
+// keystoreOptions are the options of keystore and truststore.
+type keystoreOptions struct {
+	storeType string
+	password  string
+	alias     string
+	cert      string
+	key       string
+	ca        []string
+}
+
+func parseKeystoreOptions(options interface{}, defaultAlias string, supported []string) (keystoreOptions, error) {
+	parsed := keystoreOptions{storeType: KeystorePKCS12, alias: defaultAlias}
+	optionsVal := reflect.ValueOf(options)
+	if optionsVal.Kind() != reflect.Map {
+		return parsed, fmt.Errorf("options must be a dict but was %T", options)
+	}
+	iter := optionsVal.MapRange()
+	for iter.Next() {
+		key := strval(iter.Key().Interface())
+		value := iter.Value().Interface()
+		if !contains(supported, key) {
+			return parsed, fmt.Errorf("unknown option %s, supported are [%s]", key, strings.Join(supported, ", "))
+		}
+		switch key {
+		case "type":
+			parsed.storeType = strval(value)
+			if !contains(KeystoreTypes, parsed.storeType) {
+				return parsed, fmt.Errorf("type must be one of %v, but was: %s", KeystoreTypes, parsed.storeType)
+			}
+		case "password":
+			parsed.password = strval(value)
+		case "alias":
+			parsed.alias = strval(value)
+		case "cert":
+			parsed.cert = strval(value)
+		case "key":
+			parsed.key = strval(value)
+		case "ca":
+			parsed.ca = toFlatListOfStrings(value)
+		}
+	}
+	return parsed, nil
+}
+
+// parseCertificateTexts parses the certificates of multiple PEM encoded texts.
+func parseCertificateTexts(texts []string) ([]*x509.Certificate, error) {
+	certs := make([]*x509.Certificate, 0, len(texts))
+	for _, text := range texts {
+		parsed, err := ParseCertificates([]byte(text))
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, parsed...)
+	}
+	return certs, nil
+}
+
+// keystore creates a Java keystore of PEM encoded texts and returns it binary, so it must be encoded
+// with b64enc for Kubernetes secrets or written to a file.
+//
+//	{{ keystore (dict "cert" (.Files.Get "tls.crt") "key" (.Files.Get "tls.key") "password" (env "KEYSTORE_PASSWORD")) | b64enc }}
+//
+// Supported options are type (pkcs12 (default) or jks), password, alias (default mykey, only supported
+// for jks), cert (may contain the chain), key and ca (texts appended to the chain).
+func keystore(options interface{}) (string, error) {
+	opts, err := parseKeystoreOptions(options, "", []string{"type", "password", "alias", "cert", "key", "ca"})
+	if err != nil {
+		return "", err
+	}
+	certs, err := parseCertificateTexts(append([]string{opts.cert}, opts.ca...))
+	if err != nil {
+		return "", err
+	}
+	key, err := ParsePrivateKey([]byte(opts.key))
+	if err != nil {
+		return "", err
+	}
+	store, err := NewKeystore(opts.storeType, opts.alias, key, certs, opts.password)
+	return string(store), err
+}
+
+// truststore creates a Java truststore of PEM encoded CA certificates and returns it binary.
+//
+//	{{ truststore (dict "ca" (.Files.Get "ca.crt") "password" (env "TRUSTSTORE_PASSWORD") "type" "jks") | b64enc }}
+//
+// Supported options are type (pkcs12 (default) or jks), password, alias (default caroot) and ca (texts
+// or lists of texts).
+func truststore(options interface{}) (string, error) {
+	opts, err := parseKeystoreOptions(options, "caroot", []string{"type", "password", "alias", "ca"})
+	if err != nil {
+		return "", err
+	}
+	certs, err := parseCertificateTexts(opts.ca)
+	if err != nil {
+		return "", err
+	}
+	store, err := NewTruststore(opts.storeType, opts.alias, certs, opts.password)
+	return string(store), err
+}
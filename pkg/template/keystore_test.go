@@ -0,0 +1,306 @@
+package template
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestKeystoreAlias(t *testing.T) {
+	generated, err := genCert(map[string]interface{}{"cn": "kafka", "keyType": "ecdsa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := func(extra ...string) map[string]interface{} {
+		opts := map[string]interface{}{"cert": generated.Cert, "key": generated.Key, "password": "secret"}
+		for i := 0; i+1 < len(extra); i += 2 {
+			opts[extra[i]] = extra[i+1]
+		}
+		return opts
+	}
+
+	if _, err := keystore(options()); err != nil {
+		t.Errorf("expected a pkcs12 keystore without alias, but got: %v", err)
+	}
+	if _, err := keystore(options("alias", "broker")); err == nil || !strings.Contains(err.Error(), "alias is not supported for pkcs12 keystores") {
+		t.Errorf("expected an error for an alias of a pkcs12 keystore, but got: %v", err)
+	}
+
+	tests := []struct {
+		options map[string]interface{}
+		alias   string
+	}{
+		{options("type", "jks"), "mykey"},
+		{options("type", "jks", "alias", "Broker"), "broker"},
+	}
+	for _, test := range tests {
+		store, err := keystore(test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, err := decodeJKS([]byte(store), "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].alias != test.alias {
+			t.Errorf("expected the jks keystore to contain the alias %s, but got %v", test.alias, entries)
+		}
+	}
+}
+
+// jksEntry is an entry of a JKS keystore, as read by decodeJKS.
+type jksEntry struct {
+	tag          uint32
+	alias        string
+	created      time.Time
+	protectedKey []byte
+	certs        [][]byte
+}
+
+// decodeJKS reads a JKS keystore like Java does and verifies its integrity digest.
+func decodeJKS(store []byte, password string) ([]jksEntry, error) {
+	if len(store) < sha1.Size {
+		return nil, fmt.Errorf("keystore is too short")
+	}
+	content, digest := store[:len(store)-sha1.Size], store[len(store)-sha1.Size:]
+	expected := sha1.New()
+	expected.Write(jksPassword(password))
+	expected.Write([]byte("Mighty Aphrodite"))
+	expected.Write(content)
+	if !bytes.Equal(expected.Sum(nil), digest) {
+		return nil, fmt.Errorf("keystore was tampered with, or password was incorrect")
+	}
+	reader := bytes.NewReader(content)
+	var header struct{ Magic, Version, Count uint32 }
+	if err := binary.Read(reader, binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Magic != 0xFEEDFEED || header.Version != 2 {
+		return nil, fmt.Errorf("unexpected magic %x or version %d", header.Magic, header.Version)
+	}
+	readUTF := func() (string, error) {
+		var length uint16
+		if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+			return "", err
+		}
+		text := make([]byte, length)
+		_, err := io.ReadFull(reader, text)
+		return string(text), err
+	}
+	readBytes := func() ([]byte, error) {
+		var length uint32
+		if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+		data := make([]byte, length)
+		_, err := io.ReadFull(reader, data)
+		return data, err
+	}
+	readCert := func() ([]byte, error) {
+		if certType, err := readUTF(); err != nil || certType != "X.509" {
+			return nil, fmt.Errorf("unexpected certificate type %q (%v)", certType, err)
+		}
+		return readBytes()
+	}
+	entries := make([]jksEntry, 0, header.Count)
+	for i := uint32(0); i < header.Count; i++ {
+		var entry jksEntry
+		var err error
+		if err = binary.Read(reader, binary.BigEndian, &entry.tag); err != nil {
+			return nil, err
+		}
+		if entry.alias, err = readUTF(); err != nil {
+			return nil, err
+		}
+		var created int64
+		if err = binary.Read(reader, binary.BigEndian, &created); err != nil {
+			return nil, err
+		}
+		entry.created = time.UnixMilli(created)
+		switch entry.tag {
+		case 1:
+			if entry.protectedKey, err = readBytes(); err != nil {
+				return nil, err
+			}
+			var chainLength uint32
+			if err = binary.Read(reader, binary.BigEndian, &chainLength); err != nil {
+				return nil, err
+			}
+			for j := uint32(0); j < chainLength; j++ {
+				cert, err := readCert()
+				if err != nil {
+					return nil, err
+				}
+				entry.certs = append(entry.certs, cert)
+			}
+		case 2:
+			cert, err := readCert()
+			if err != nil {
+				return nil, err
+			}
+			entry.certs = [][]byte{cert}
+		default:
+			return nil, fmt.Errorf("unexpected tag %d", entry.tag)
+		}
+		entries = append(entries, entry)
+	}
+	if reader.Len() != 0 {
+		return nil, fmt.Errorf("%d unexpected bytes after the entries", reader.Len())
+	}
+	return entries, nil
+}
+
+// jksRecoverKey decrypts a key which is protected by the key protector of Java.
+func jksRecoverKey(protectedKey []byte, password string) ([]byte, error) {
+	var info jksEncryptedPrivateKeyInfo
+	if rest, err := asn1.Unmarshal(protectedKey, &info); err != nil || len(rest) > 0 {
+		return nil, fmt.Errorf("invalid encrypted private key info: %v", err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidJksKeyProtector) {
+		return nil, fmt.Errorf("unexpected algorithm %v", info.Algorithm.Algorithm)
+	}
+	data := info.EncryptedData
+	if len(data) < 2*sha1.Size {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
+	passwordBytes := jksPassword(password)
+	encrypted, checksum := data[sha1.Size:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	key := make([]byte, 0, len(encrypted))
+	digest := data[:sha1.Size]
+	for offset := 0; offset < len(encrypted); offset += sha1.Size {
+		sum := sha1.Sum(append(append([]byte{}, passwordBytes...), digest...))
+		digest = sum[:]
+		for i := 0; i < sha1.Size && offset+i < len(encrypted); i++ {
+			key = append(key, encrypted[offset+i]^digest[i])
+		}
+	}
+	if expected := sha1.Sum(append(append([]byte{}, passwordBytes...), key...)); !bytes.Equal(expected[:], checksum) {
+		return nil, fmt.Errorf("checksum of the key does not match")
+	}
+	return key, nil
+}
+
+func pemBytes(t *testing.T, text string) []byte {
+	block, _ := pem.Decode([]byte(text))
+	if block == nil {
+		t.Fatalf("invalid PEM: %s", text)
+	}
+	return block.Bytes
+}
+
+func generateChain(t *testing.T) (GeneratedCert, GeneratedCert) {
+	ca, err := genCert(map[string]interface{}{"cn": "ca", "ca": true, "keyType": "ecdsa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker, err := genCert(map[string]interface{}{"cn": "broker", "keyType": "ecdsa", "signer": ca})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, broker
+}
+
+func TestKeystoreJKSRoundTrip(t *testing.T) {
+	ca, broker := generateChain(t)
+	store, err := keystore(map[string]interface{}{"type": "jks", "alias": "Broker", "password": "secret",
+		"cert": broker.Cert, "key": broker.Key, "ca": ca.Cert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeJKS([]byte(store), "wrong"); err == nil {
+		t.Error("expected the integrity digest not to match with a wrong password")
+	}
+	entries, err := decodeJKS([]byte(store), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry, but got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.tag != 1 || entry.alias != "broker" {
+		t.Errorf("expected a private key entry broker, but got tag %d and alias %s", entry.tag, entry.alias)
+	}
+	if time.Since(entry.created) > time.Minute || time.Until(entry.created) > time.Second {
+		t.Errorf("unexpected creation date %v", entry.created)
+	}
+	if len(entry.certs) != 2 || !bytes.Equal(entry.certs[0], pemBytes(t, broker.Cert)) || !bytes.Equal(entry.certs[1], pemBytes(t, ca.Cert)) {
+		t.Errorf("expected the chain of broker and ca, but got %d certificates", len(entry.certs))
+	}
+	if _, err := jksRecoverKey(entry.protectedKey, "wrong"); err == nil {
+		t.Error("expected the key not to be recovered with a wrong password")
+	}
+	key, err := jksRecoverKey(entry.protectedKey, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, pemBytes(t, broker.Key)) {
+		t.Error("expected the recovered key to be the PKCS #8 key")
+	}
+}
+
+func TestTruststoreJKSRoundTrip(t *testing.T) {
+	ca, broker := generateChain(t)
+	store, err := truststore(map[string]interface{}{"type": "jks", "password": "secret", "ca": []interface{}{ca.Cert, broker.Cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := decodeJKS([]byte(store), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		alias string
+		cert  string
+	}{{"caroot-0", ca.Cert}, {"caroot-1", broker.Cert}}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, but got %d", len(expected), len(entries))
+	}
+	for i, entry := range entries {
+		if entry.tag != 2 || entry.alias != expected[i].alias || len(entry.certs) != 1 || !bytes.Equal(entry.certs[0], pemBytes(t, expected[i].cert)) {
+			t.Errorf("entry %d: expected trusted certificate %s, but got tag %d and alias %s", i, expected[i].alias, entry.tag, entry.alias)
+		}
+	}
+}
+
+func TestKeystorePKCS12RoundTrip(t *testing.T) {
+	ca, broker := generateChain(t)
+	for _, password := range []string{"secret", ""} {
+		store, err := keystore(map[string]interface{}{"password": password, "cert": broker.Cert, "key": broker.Key, "ca": ca.Cert})
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, cert, caCerts, err := pkcs12.DecodeChain([]byte(store), password)
+		if err != nil {
+			t.Fatalf("password %q: %v", password, err)
+		}
+		if !bytes.Equal(cert.Raw, pemBytes(t, broker.Cert)) || len(caCerts) != 1 || !bytes.Equal(caCerts[0].Raw, pemBytes(t, ca.Cert)) {
+			t.Errorf("password %q: expected the chain of broker and ca, but got %s and %d ca certificates", password, cert.Subject, len(caCerts))
+		}
+		if signer, ok := key.(crypto.Signer); !ok || !KeyMatchesCertificate(signer, cert) {
+			t.Errorf("password %q: expected the key of the certificate", password)
+		}
+	}
+
+	store, err := truststore(map[string]interface{}{"password": "secret", "ca": []interface{}{ca.Cert, broker.Cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := pkcs12.DecodeTrustStore([]byte(store), "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 2 || !bytes.Equal(certs[0].Raw, pemBytes(t, ca.Cert)) || !bytes.Equal(certs[1].Raw, pemBytes(t, broker.Cert)) {
+		t.Errorf("expected the certificates ca and broker, but got %d certificates", len(certs))
+	}
+}
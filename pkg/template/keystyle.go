@@ -0,0 +1,356 @@
+package template
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Presets of key styles, which can be used by name instead of a custom specification.
+var keyStylePresets = map[string]string{
+	"confluent":      "_=.,__=_,___=-,case=lower",
+	"spring-relaxed": "_=.,case=lower,index=brackets,drop=-",
+	"camelCase":      "_=,__=.,case=camel",
+	"kebab":          "_=.,__=-,case=lower",
+}
+
+const (
+	keyCaseLower    string = "lower"
+	keyCaseUpper    string = "upper"
+	keyCasePreserve string = "preserve"
+	keyCaseCamel    string = "camel"
+)
+
+// KeyStyle defines how environment variable names are transformed into configuration keys and back.
+//
+// A key style is either the name of a preset (confluent, spring-relaxed, camelCase, kebab) or a comma
+// separated specification. Each run of underscores (e.g. '_' or '__') is mapped to a separator, and
+// the options case (lower, upper, preserve, camel), index (brackets) and drop are supported:
+//
+//	_=.,__=_,___=-,case=lower            (confluent, the convention of envToProp)
+//	_=.,case=lower,index=brackets,drop=- (spring-relaxed, e.g. MY_LIST_0_NAME -> my.list[0].name)
+//	_=,__=.,case=camel                   (camelCase, e.g. ZOOKEEPER__CLIENT_CNXN_SOCKET -> zookeeper.clientCnxnSocket)
+//	_=.,__=-,case=lower                  (kebab, e.g. SPRING_JPA_SHOW__SQL -> spring.jpa.show-sql)
+//
+// A single underscore is only mapped between two words. Longer runs without own separator are split
+// into the longest runs with a separator, e.g. '____' into '___' and '_' for confluent, and remaining
+// underscores are kept. With case camel, words which follow an empty separator start with an upper case letter.
+// With index brackets, numeric words which follow the separator of a single underscore are put
+// in brackets. Characters in drop are removed if keys are transformed into environment variable names.
+type KeyStyle struct {
+	Separators map[int]string
+	Case       string
+	Index      string
+	Drop       string
+}
+
+// confluentKeyStyle is the key style of envToProp and propToEnv.
+var confluentKeyStyle = func() *KeyStyle {
+	style, err := ParseKeyStyle("confluent")
+	if err != nil {
+		panic(err)
+	}
+	return style
+}()
+
+// ParseKeyStyle parses the name of a preset or a custom specification.
+func ParseKeyStyle(spec string) (*KeyStyle, error) {
+	if preset, isPreset := keyStylePresets[spec]; isPreset {
+		return parseKeyStyleSpec(preset)
+	}
+	style, err := parseKeyStyleSpec(spec)
+	if err != nil {
+		return nil, fmt.Errorf("key style must be one of %v or a specification like '_=.,__=_,case=lower', but was: %s (%v)",
+			keyStylePresetNames(), spec, err)
+	}
+	return style, nil
+}
+
+func keyStylePresetNames() []string {
+	names := make([]string, 0, len(keyStylePresets))
+	for name := range keyStylePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func parseKeyStyleSpec(spec string) (*KeyStyle, error) {
+	style := &KeyStyle{Separators: make(map[int]string), Case: keyCaseLower}
+	for _, entry := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry '%s', expected key=value", entry)
+		}
+		switch {
+		case key != "" && strings.Trim(key, "_") == "":
+			if strings.Contains(value, "_") && value != "_" && len(key) == 1 {
+				return nil, fmt.Errorf("separator of a single underscore must not contain underscores")
+			}
+			style.Separators[len(key)] = value
+		case key == "case":
+			if value != keyCaseLower && value != keyCaseUpper && value != keyCasePreserve && value != keyCaseCamel {
+				return nil, fmt.Errorf("case must be one of [%s, %s, %s, %s], but was: %s", keyCaseLower, keyCaseUpper, keyCasePreserve, keyCaseCamel, value)
+			}
+			style.Case = value
+		case key == "index":
+			if value != "brackets" && value != "" {
+				return nil, fmt.Errorf("index must be brackets, but was: %s", value)
+			}
+			style.Index = value
+		case key == "drop":
+			style.Drop = value
+		default:
+			return nil, fmt.Errorf("unknown key '%s'", key)
+		}
+	}
+	if len(style.Separators) == 0 {
+		return nil, fmt.Errorf("at least one separator is required")
+	}
+	return style, nil
+}
+
+// EnvToKey transforms the name of an environment variable (without prefix) into a configuration key.
+func (s *KeyStyle) EnvToKey(env string) string {
+	var b strings.Builder
+	tokens := splitUnderscoreRuns(env)
+	capitalizeNext := false
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if !strings.HasPrefix(token, "_") {
+			word := s.applyCase(token)
+			if capitalizeNext {
+				word = capitalize(word)
+			}
+			b.WriteString(word)
+			capitalizeNext = false
+			continue
+		}
+		betweenWords := i > 0 && i < len(tokens)-1
+		if s.Index == "brackets" && len(token) == 1 && betweenWords && isDigits(tokens[i+1]) {
+			if _, mapped := s.Separators[1]; mapped {
+				b.WriteString("[" + tokens[i+1] + "]")
+				i++
+				continue
+			}
+		}
+		separator, mapped := s.runSeparator(len(token), betweenWords)
+		capitalizeNext = s.Case == keyCaseCamel && mapped && separator == "" && b.Len() > 0
+		b.WriteString(separator)
+	}
+	return b.String()
+}
+
+// runSeparator returns the separator of a run of underscores and if it is mapped.
+func (s *KeyStyle) runSeparator(n int, betweenWords bool) (string, bool) {
+	if n == 1 {
+		if separator, mapped := s.Separators[1]; mapped && betweenWords {
+			return separator, true
+		}
+		return "_", false
+	}
+	var b strings.Builder
+	mapped := false
+	for n > 0 {
+		length := n
+		for ; length > 1; length-- {
+			if _, ok := s.Separators[length]; ok {
+				break
+			}
+		}
+		if length == 1 {
+			b.WriteString(strings.Repeat("_", n))
+			break
+		}
+		b.WriteString(s.Separators[length])
+		mapped = true
+		n -= length
+	}
+	return b.String(), mapped
+}
+
+// KeyToEnv transforms a configuration key into the name of an environment variable (without prefix).
+// An error is returned if the key cannot be represented unambiguously, together with the best possible name.
+func (s *KeyStyle) KeyToEnv(key string) (string, error) {
+	return s.PropertyToEnv("", "", key)
+}
+
+// PropertyToEnv converts a configuration key with prefix into the name of the environment variable, which
+// is converted back into the same key by envToConfig with this prefix. An error is returned if the key
+// cannot be represented unambiguously, together with the best possible name.
+func (s *KeyStyle) PropertyToEnv(keyPrefix string, envPrefix string, key string) (string, error) {
+	if !strings.HasPrefix(key, keyPrefix) {
+		return "", fmt.Errorf("property does not start with prefix %s", keyPrefix)
+	}
+	env := envPrefix + s.keyToEnv(strings.TrimPrefix(key, keyPrefix))
+	expected := key
+	for _, dropped := range s.Drop {
+		expected = strings.ReplaceAll(expected, string(dropped), "")
+	}
+	if roundTrip := s.EnvToKey(keyPrefix + strings.TrimPrefix(env, envPrefix)); roundTrip != expected {
+		return env, fmt.Errorf("cannot be represented unambiguously, %s is converted back to %s", env, roundTrip)
+	}
+	return env, nil
+}
+
+func (s *KeyStyle) keyToEnv(key string) string {
+	separators := make([]string, 0)
+	underscores := make(map[string]string)
+	camelUnderscores, hasCamel := "", false
+	for n, separator := range s.Separators {
+		if separator == "" {
+			camelUnderscores, hasCamel = strings.Repeat("_", n), true
+			continue
+		}
+		separators = append(separators, separator)
+		underscores[separator] = strings.Repeat("_", n)
+	}
+	// longest separators first, so that e.g. '..' has precedence over '.'
+	sort.Slice(separators, func(i, j int) bool { return len(separators[i]) > len(separators[j]) })
+
+	var b strings.Builder
+	runes := []rune(key)
+	for i := 0; i < len(runes); {
+		rest := string(runes[i:])
+		if strings.ContainsRune(s.Drop, runes[i]) {
+			i++
+			continue
+		}
+		if s.Index == "brackets" && runes[i] == '[' {
+			if end := strings.IndexRune(rest, ']'); end > 1 && isDigits(rest[1:end]) {
+				b.WriteString("_" + rest[1:end])
+				i += len([]rune(rest[:end+1]))
+				continue
+			}
+		}
+		matched := false
+		for _, separator := range separators {
+			if strings.HasPrefix(rest, separator) {
+				b.WriteString(underscores[separator])
+				i += len([]rune(separator))
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		r := runes[i]
+		if s.Case == keyCaseCamel && hasCamel && unicode.IsUpper(r) && i > 0 {
+			b.WriteString(camelUnderscores)
+		}
+		b.WriteRune(r)
+		i++
+	}
+	if s.Case == keyCaseLower || s.Case == keyCaseCamel {
+		return strings.ToUpper(b.String())
+	}
+	return b.String()
+}
+
+func (s *KeyStyle) applyCase(word string) string {
+	switch s.Case {
+	case keyCaseLower, keyCaseCamel:
+		return strings.ToLower(word)
+	case keyCaseUpper:
+		return strings.ToUpper(word)
+	default:
+		return word
+	}
+}
+
+// splitUnderscoreRuns splits a text into words and runs of underscores, e.g. A__B_C into [A __ B _ C].
+func splitUnderscoreRuns(text string) []string {
+	tokens := make([]string, 0)
+	start := 0
+	for i := 1; i <= len(text); i++ {
+		if i == len(text) || (text[i] == '_') != (text[start] == '_') {
+			tokens = append(tokens, text[start:i])
+			start = i
+		}
+	}
+	return tokens
+}
+
+func isDigits(text string) bool {
+	if text == "" {
+		return false
+	}
+	_, err := strconv.ParseUint(text, 10, 64)
+	return err == nil
+}
+
+func capitalize(word string) string {
+	runes := []rune(word)
+	if len(runes) == 0 {
+		return word
+	}
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// Converts environment variables with a prefix into configuration keys using a key style. Like for
+// envToProp, which uses the key style confluent, the env prefix is replaced by the key prefix, before
+// the key is transformed.
+//
+//	{{ envToConfig "camelCase" "ES_" "" | toYAML }}
+func envToConfig(style string, env_prefix string, key_prefix string, exclude ...interface{}) (map[string]interface{}, error) {
+	keyStyle, err := ParseKeyStyle(style)
+	if err != nil {
+		return nil, err
+	}
+	config := make(map[string]interface{})
+	for env, value := range excludeKeys(exclude, envToMap(env_prefix)) {
+		config[keyStyle.EnvToKey(key_prefix+strings.TrimPrefix(env, env_prefix))] = value
+	}
+	return config, nil
+}
+
+// toConfigKey transforms all keys of a map with a key style, like toPropertiesKey does for the style confluent.
+func toConfigKey(style string, sourceMap interface{}) (map[string]interface{}, error) {
+	keyStyle, err := ParseKeyStyle(style)
+	if err != nil {
+		return nil, err
+	}
+	sourceMapVal := mapValueOf(sourceMap)
+	if sourceMapVal.Kind() != reflect.Map {
+		return nil, fmt.Errorf("must be a map but was %T", sourceMap)
+	}
+	config := make(map[string]interface{})
+	iter := sourceMapVal.MapRange()
+	for iter.Next() {
+		config[keyStyle.EnvToKey(strval(iter.Key().Interface()))] = iter.Value().Interface()
+	}
+	return config, nil
+}
+
+// configToEnv is the inverse of envToConfig. The key prefix is removed from the keys, and the env prefix
+// is added. Keys without the prefix are ignored, and keys which cannot be represented unambiguously
+// result in an error.
+func configToEnv(style string, key_prefix string, env_prefix string, config interface{}) (map[string]interface{}, error) {
+	keyStyle, err := ParseKeyStyle(style)
+	if err != nil {
+		return nil, err
+	}
+	sourceMapVal := mapValueOf(config)
+	if sourceMapVal.Kind() != reflect.Map {
+		return nil, fmt.Errorf("must be a map but was %T", config)
+	}
+	env := make(map[string]interface{})
+	iter := sourceMapVal.MapRange()
+	for iter.Next() {
+		key := strval(iter.Key().Interface())
+		if !strings.HasPrefix(key, key_prefix) {
+			continue
+		}
+		name, err := keyStyle.PropertyToEnv(key_prefix, env_prefix, key)
+		if err != nil {
+			return nil, fmt.Errorf("%s %v", key, err)
+		}
+		env[name] = iter.Value().Interface()
+	}
+	return env, nil
+}
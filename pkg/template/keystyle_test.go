@@ -0,0 +1,92 @@
+package template
+
+import (
+	"testing"
+)
+
+func TestKeyStyleEnvToKey(t *testing.T) {
+	tests := []struct {
+		style    string
+		env      string
+		expected string
+	}{
+		{"confluent", "NUM_NETWORK__THREADS", "num.network_threads"},
+		{"confluent", "LOG_CLEANER___ENABLE", "log.cleaner-enable"},
+		{"confluent", "_LEADING", "_leading"},
+		{"confluent", "TRAILING_", "trailing_"},
+		{"confluent", "__LEADING", "_leading"},
+		{"confluent", "A____B", "a-_b"},
+		{"confluent", "A_____B", "a-_b"},
+		{"confluent", "A______B", "a--b"},
+		{"spring-relaxed", "MY_LIST_0_NAME", "my.list[0].name"},
+		{"spring-relaxed", "MY__LIST", "my__list"},
+		{"camelCase", "ZOOKEEPER__CLIENT_CNXN_SOCKET", "zookeeper.clientCnxnSocket"},
+		{"kebab", "SPRING_JPA_SHOW__SQL", "spring.jpa.show-sql"},
+		{"_=/,__=.,case=preserve", "KV_Path__Name", "KV/Path.Name"},
+	}
+	for _, test := range tests {
+		style, err := ParseKeyStyle(test.style)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key := style.EnvToKey(test.env); key != test.expected {
+			t.Errorf("%s %s: expected %q, but got %q", test.style, test.env, test.expected, key)
+		}
+	}
+}
+
+func TestKeyStylePropertyToEnv(t *testing.T) {
+	tests := []struct {
+		style     string
+		keyPrefix string
+		envPrefix string
+		key       string
+		expected  string
+		ambiguous bool
+	}{
+		{"confluent", "", "KAFKA_", "num.network_threads", "KAFKA_NUM_NETWORK__THREADS", false},
+		{"confluent", "confluent.", "CONFLUENT_", "confluent.log-dir", "CONFLUENT_LOG___DIR", false},
+		{"confluent", "", "", "Upper.Case", "UPPER_CASE", true},
+		{"confluent", "", "", "a..b", "A__B", true},
+		{"camelCase", "es.", "ES_", "es.clusterName", "ES_CLUSTER_NAME", false},
+		{"spring-relaxed", "", "APP_", "my.list[0].first-name", "APP_MY_LIST_0_FIRSTNAME", false},
+	}
+	for _, test := range tests {
+		style, err := ParseKeyStyle(test.style)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env, err := style.PropertyToEnv(test.keyPrefix, test.envPrefix, test.key)
+		if env != test.expected || (err != nil) != test.ambiguous {
+			t.Errorf("%s %s: expected %q (ambiguous %v), but got %q (%v)", test.style, test.key, test.expected, test.ambiguous, env, err)
+		}
+	}
+}
+
+func TestEnvToPropIsConfluentEnvToConfig(t *testing.T) {
+	t.Setenv("GODUB_TEST_LOG_DIRS", "/data")
+	t.Setenv("GODUB_TEST_NUM__THREADS", "3")
+	t.Setenv("GODUB_TEST_HEAP_OPTS", "-Xmx1g")
+	for _, prefix := range []string{"", "kafka.", "KAFKA_"} {
+		props := envToProp("GODUB_TEST_", prefix, "GODUB_TEST_HEAP_OPTS")
+		config, err := envToConfig("confluent", "GODUB_TEST_", prefix, "GODUB_TEST_HEAP_OPTS")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if props.Len() != 2 || len(config) != props.Len() {
+			t.Fatalf("prefix %q: unexpected properties %v and config %v", prefix, props, config)
+		}
+		if keys := props.Keys(); keys[0] > keys[1] {
+			t.Errorf("prefix %q: expected sorted keys, but got %v", prefix, keys)
+		}
+		for _, entry := range props.Entries() {
+			key, value := entry.Key, entry.Value
+			if config[key] != value {
+				t.Errorf("prefix %q: expected %s=%v, but got %v", prefix, key, value, config[key])
+			}
+		}
+	}
+	if props := envToProp("GODUB_TEST_", "KAFKA_"); props.Get("kafka.log.dirs") != "/data" || props.Get("kafka.num_threads") != "3" {
+		t.Errorf("unexpected properties: %v", props)
+	}
+}
@@ -0,0 +1,474 @@
+package template
+
+import (
+	"encoding/csv"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Duplicate key policies of kvCsvToMapWith.
+const (
+	duplicatesLast  string = "last"
+	duplicatesFirst string = "first"
+	duplicatesError string = "error"
+	duplicatesList  string = "list"
+)
+
+var duplicatesPolicies = []string{duplicatesLast, duplicatesFirst, duplicatesError, duplicatesList}
+
+// kvOptions are the options of kvCsvToMapWith and mapToKvCsvWith. The defaults are compatible
+// with kvCsvToMap, which does not support quotes.
+type kvOptions struct {
+	separator     string
+	pairSeparator string
+	quote         string
+	escape        string
+	trim          bool
+	duplicates    string
+	strict        bool
+}
+
+func parseKvOptions(options interface{}) (kvOptions, error) {
+	parsed := kvOptions{separator: ",", pairSeparator: "=", duplicates: duplicatesLast}
+	optionsVal := reflect.ValueOf(options)
+	if optionsVal.Kind() != reflect.Map {
+		return parsed, fmt.Errorf("options must be a dict but was %T", options)
+	}
+	iter := optionsVal.MapRange()
+	for iter.Next() {
+		key := strval(iter.Key().Interface())
+		value := strval(iter.Value().Interface())
+		switch key {
+		case "separator", "pairSeparator":
+			if value == "" {
+				return parsed, fmt.Errorf("%s must not be empty", key)
+			}
+			if key == "separator" {
+				parsed.separator = value
+			} else {
+				parsed.pairSeparator = value
+			}
+		case "quote":
+			parsed.quote = value
+		case "escape":
+			parsed.escape = value
+		case "trim", "strict":
+			flag, err := strconv.ParseBool(value)
+			if err != nil {
+				return parsed, fmt.Errorf("%s must be a bool, but was: %s", key, value)
+			}
+			if key == "trim" {
+				parsed.trim = flag
+			} else {
+				parsed.strict = flag
+			}
+		case "duplicates":
+			if !contains(duplicatesPolicies, value) {
+				return parsed, fmt.Errorf("duplicates must be one of %v, but was: %s", duplicatesPolicies, value)
+			}
+			parsed.duplicates = value
+		default:
+			return parsed, fmt.Errorf("unknown option %s, supported are [separator, pairSeparator, quote, escape, trim, duplicates, strict]", key)
+		}
+	}
+	return parsed, nil
+}
+
+// kvScanner reads the keys and values of a list of key-value pairs.
+type kvScanner struct {
+	text string
+	pos  int
+	opts kvOptions
+}
+
+// field reads a key or value until one of the stops, and returns the stop which was found
+// (empty at the end of the text). A field which starts with the quote is read until the
+// closing quote, in which doubled quotes are a quote. The escape takes the next character literally.
+func (s *kvScanner) field(stops ...string) (string, string, error) {
+	start := s.pos
+	if s.opts.trim {
+		for s.pos < len(s.text) && (s.text[s.pos] == ' ' || s.text[s.pos] == '\t') {
+			s.pos++
+		}
+	}
+	var quoted strings.Builder
+	isQuoted := s.opts.quote != "" && strings.HasPrefix(s.text[s.pos:], s.opts.quote)
+	if isQuoted {
+		s.pos += len(s.opts.quote)
+		for closed := false; !closed; {
+			switch {
+			case s.pos >= len(s.text):
+				if s.opts.strict {
+					return "", "", fmt.Errorf("quote at position %d is not closed", start)
+				}
+				return quoted.String(), "", nil
+			case s.opts.escape != "" && strings.HasPrefix(s.text[s.pos:], s.opts.escape):
+				s.escaped(&quoted)
+			case strings.HasPrefix(s.text[s.pos:], s.opts.quote+s.opts.quote):
+				quoted.WriteString(s.opts.quote)
+				s.pos += 2 * len(s.opts.quote)
+			case strings.HasPrefix(s.text[s.pos:], s.opts.quote):
+				s.pos += len(s.opts.quote)
+				closed = true
+			default:
+				quoted.WriteByte(s.text[s.pos])
+				s.pos++
+			}
+		}
+	}
+	var rest strings.Builder
+	stop := ""
+	for s.pos < len(s.text) && stop == "" {
+		if stop = s.stopAt(stops); stop != "" {
+			s.pos += len(stop)
+		} else if s.opts.escape != "" && strings.HasPrefix(s.text[s.pos:], s.opts.escape) {
+			s.escaped(&rest)
+		} else {
+			rest.WriteByte(s.text[s.pos])
+			s.pos++
+		}
+	}
+	text := rest.String()
+	if s.opts.trim {
+		text = strings.TrimSpace(text)
+	}
+	if isQuoted && text != "" && s.opts.strict {
+		return "", "", fmt.Errorf("unexpected text %q after quote at position %d", text, start)
+	}
+	return quoted.String() + text, stop, nil
+}
+
+func (s *kvScanner) stopAt(stops []string) string {
+	for _, stop := range stops {
+		if strings.HasPrefix(s.text[s.pos:], stop) {
+			return stop
+		}
+	}
+	return ""
+}
+
+func (s *kvScanner) escaped(b *strings.Builder) {
+	s.pos += len(s.opts.escape)
+	if s.pos < len(s.text) {
+		r, size := utf8.DecodeRuneInString(s.text[s.pos:])
+		b.WriteRune(r)
+		s.pos += size
+	}
+}
+
+// kvCsvToMapWith parses a list of key-value pairs (e.g. k1=v1,k2=v2) into a map, with options passed
+// as dict, e.g.
+//
+//	{{ env "KAFKA_LOG4J_LOGGERS" | kvCsvToMapWith (dict "quote" "\"" "trim" true "strict" true) }}
+//
+// Supported options are separator (default ','), pairSeparator (default '='), quote (e.g. '"', disabled
+// by default), escape (e.g. '\', disabled by default), trim (removes whitespace around keys and values),
+// duplicates ('last' (default), 'first', 'error' or 'list', which collects the values) and strict (fails
+// on entries without pair separator, empty keys and malformed quotes, which are skipped or read
+// literally otherwise). With a quote, keys and values which start with the quote are read until
+// the closing quote, so that they can contain separators. Within quotes, a doubled quote is a quote.
+func kvCsvToMapWith(options interface{}, text string) (map[string]interface{}, error) {
+	opts, err := parseKvOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{})
+	scanner := &kvScanner{text: text, opts: opts}
+	for scanner.pos < len(text) {
+		start := scanner.pos
+		key, stop, err := scanner.field(opts.separator, opts.pairSeparator)
+		if err != nil {
+			return nil, err
+		}
+		if stop != opts.pairSeparator {
+			if key != "" && opts.strict {
+				return nil, fmt.Errorf("entry %q at position %d has no %q", key, start, opts.pairSeparator)
+			}
+			continue
+		}
+		value, _, err := scanner.field(opts.separator)
+		if err != nil {
+			return nil, err
+		}
+		if key == "" && opts.strict {
+			return nil, fmt.Errorf("entry at position %d has an empty key", start)
+		}
+		existing, exists := result[key]
+		switch {
+		case !exists && opts.duplicates == duplicatesList:
+			result[key] = []interface{}{value}
+		case !exists || opts.duplicates == duplicatesLast:
+			result[key] = value
+		case opts.duplicates == duplicatesList:
+			result[key] = append(existing.([]interface{}), value)
+		case opts.duplicates == duplicatesError:
+			return nil, fmt.Errorf("key %s is defined more than once", key)
+		}
+	}
+	return result, nil
+}
+
+// mapToKvCsv writes a map as list of key-value pairs (k1=v1,k2=v2), which is the inverse of kvCsvToMap.
+// The keys are sorted. Keys and values with separators are an error, because they cannot be read by
+// kvCsvToMap; use mapToKvCsvWith with a quote or an escape for them.
+func mapToKvCsv(v interface{}) (string, error) {
+	return mapToKvCsvWith(map[string]interface{}{}, v)
+}
+
+// mapToKvCsvWith is mapToKvCsv with the options separator, pairSeparator, quote and escape of
+// kvCsvToMapWith. Keys and values are quoted if required, or escaped if quoting is disabled.
+// Lists are written as one pair per value. An OrderedMap keeps its order.
+func mapToKvCsvWith(options interface{}, v interface{}) (string, error) {
+	opts, err := parseKvOptions(options)
+	if err != nil {
+		return "", err
+	}
+	var entries []OrderedMapEntry
+	if ordered, isOrdered := v.(*OrderedMap); isOrdered {
+		entries = ordered.Entries()
+	} else {
+		m, isMap := asStringMap(v)
+		if !isMap {
+			return "", fmt.Errorf("must be a map but was %T", v)
+		}
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			entries = append(entries, OrderedMapEntry{Key: key, Value: m[key]})
+		}
+	}
+	pairs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		key, err := quoteKv(entry.Key, opts, opts.separator, opts.pairSeparator)
+		if err != nil {
+			return "", err
+		}
+		values := []interface{}{entry.Value}
+		if list, isList := entry.Value.([]interface{}); isList {
+			values = list
+		}
+		for _, value := range values {
+			text, err := quoteKv(strval(value), opts, opts.separator)
+			if err != nil {
+				return "", err
+			}
+			pairs = append(pairs, key+opts.pairSeparator+text)
+		}
+	}
+	return strings.Join(pairs, opts.separator), nil
+}
+
+// quoteKv quotes a key or value, if it contains one of the separators, starts with the quote or
+// has surrounding whitespace. Without quote, these characters are escaped.
+func quoteKv(text string, opts kvOptions, separators ...string) (string, error) {
+	special := append([]string{}, separators...)
+	if opts.escape != "" {
+		special = append(special, opts.escape)
+	}
+	required := strings.TrimSpace(text) != text
+	if opts.quote != "" && strings.HasPrefix(text, opts.quote) {
+		required = true
+	}
+	for _, s := range special {
+		required = required || strings.Contains(text, s)
+	}
+	switch {
+	case !required:
+		return text, nil
+	case opts.quote != "" && opts.escape != "":
+		escaped := strings.ReplaceAll(text, opts.escape, opts.escape+opts.escape)
+		return opts.quote + strings.ReplaceAll(escaped, opts.quote, opts.escape+opts.quote) + opts.quote, nil
+	case opts.quote != "":
+		return opts.quote + strings.ReplaceAll(text, opts.quote, opts.quote+opts.quote) + opts.quote, nil
+	case opts.escape != "":
+		escaped := strings.ReplaceAll(text, opts.escape, opts.escape+opts.escape)
+		for _, s := range separators {
+			escaped = strings.ReplaceAll(escaped, s, opts.escape+s)
+		}
+		return escaped, nil
+	default:
+		return "", fmt.Errorf("%q cannot be written without quote or escape", text)
+	}
+}
+
+// csvOptions are the options of fromCSVWith and toCSVWith.
+type csvOptions struct {
+	separator rune
+	comment   rune
+	header    bool
+	trim      bool
+	crlf      bool
+	columns   []string
+}
+
+func parseCsvOptions(options interface{}) (csvOptions, error) {
+	parsed := csvOptions{separator: ',', header: true}
+	optionsVal := reflect.ValueOf(options)
+	if optionsVal.Kind() != reflect.Map {
+		return parsed, fmt.Errorf("options must be a dict but was %T", options)
+	}
+	iter := optionsVal.MapRange()
+	for iter.Next() {
+		key := strval(iter.Key().Interface())
+		value := iter.Value().Interface()
+		switch key {
+		case "separator", "comment":
+			r, size := utf8.DecodeRuneInString(strval(value))
+			if size == 0 || size != len(strval(value)) {
+				return parsed, fmt.Errorf("%s must be a single character, but was: %q", key, strval(value))
+			}
+			if key == "separator" {
+				parsed.separator = r
+			} else {
+				parsed.comment = r
+			}
+		case "header", "trim", "crlf":
+			flag, err := strconv.ParseBool(strval(value))
+			if err != nil {
+				return parsed, fmt.Errorf("%s must be a bool, but was: %v", key, value)
+			}
+			switch key {
+			case "header":
+				parsed.header = flag
+			case "trim":
+				parsed.trim = flag
+			default:
+				parsed.crlf = flag
+			}
+		case "columns":
+			parsed.columns = toFlatListOfStrings(value)
+		default:
+			return parsed, fmt.Errorf("unknown option %s, supported are [separator, comment, header, trim, crlf, columns]", key)
+		}
+	}
+	return parsed, nil
+}
+
+// fromCSV parses CSV (RFC 4180) with a header into a list of dicts.
+//
+//	{{ range fromCSV (.Files.Get "users.csv") }}{{ .name }}:{{ .role }}{{ end }}
+func fromCSV(text string) ([]interface{}, error) {
+	return fromCSVWith(map[string]interface{}{}, text)
+}
+
+// fromCSVWith is fromCSV with options, passed as dict. Supported options are separator (default ','),
+// comment (character which starts comment lines), trim (removes leading whitespace of fields), and header
+// (default true). Without header, a list of lists is returned. The option columns defines the keys
+// of the dicts for files without header.
+func fromCSVWith(options interface{}, text string) ([]interface{}, error) {
+	opts, err := parseCsvOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = opts.separator
+	reader.Comment = opts.comment
+	reader.TrimLeadingSpace = opts.trim
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	columns := opts.columns
+	if opts.header && len(columns) == 0 && len(records) > 0 {
+		columns, records = records[0], records[1:]
+	}
+	rows := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		if len(columns) == 0 {
+			row := make([]interface{}, len(record))
+			for i, field := range record {
+				row[i] = field
+			}
+			rows = append(rows, row)
+			continue
+		}
+		if len(record) != len(columns) {
+			return nil, fmt.Errorf("record %v has %d fields, but there are %d columns", record, len(record), len(columns))
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			row[column] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// toCSV writes a list of dicts with a header, or a list of lists, as CSV (RFC 4180).
+func toCSV(rows interface{}) (string, error) {
+	return toCSVWith(map[string]interface{}{}, rows)
+}
+
+// toCSVWith is toCSV with options, passed as dict. Supported options are separator (default ','),
+// header (default true), columns (the keys and order of the columns of dicts, by default the sorted keys
+// of all dicts, or the keys of the first OrderedMap) and crlf (line breaks as defined by RFC 4180).
+func toCSVWith(options interface{}, rows interface{}) (string, error) {
+	opts, err := parseCsvOptions(options)
+	if err != nil {
+		return "", err
+	}
+	list, isList := asList(rows)
+	if !isList {
+		return "", fmt.Errorf("must be a list but was %T", rows)
+	}
+	columns := opts.columns
+	if len(columns) == 0 {
+		columns = csvColumns(list)
+	}
+	var buf strings.Builder
+	writer := csv.NewWriter(&buf)
+	writer.Comma = opts.separator
+	writer.UseCRLF = opts.crlf
+	if opts.header && len(columns) > 0 {
+		if err := writer.Write(columns); err != nil {
+			return "", err
+		}
+	}
+	for _, row := range list {
+		var record []string
+		if fields, isFields := asList(row); isFields {
+			for _, field := range fields {
+				record = append(record, strval(field))
+			}
+		} else if m, isMap := asStringMap(row); isMap {
+			for _, column := range columns {
+				record = append(record, strval(m[column]))
+			}
+		} else {
+			return "", fmt.Errorf("rows must be lists or dicts, but was %T", row)
+		}
+		if err := writer.Write(record); err != nil {
+			return "", err
+		}
+	}
+	writer.Flush()
+	return buf.String(), writer.Error()
+}
+
+// csvColumns returns the keys of the first OrderedMap in their order, or the sorted keys of all dicts.
+func csvColumns(rows []interface{}) []string {
+	if len(rows) > 0 {
+		if ordered, isOrdered := rows[0].(*OrderedMap); isOrdered {
+			return ordered.Keys()
+		}
+	}
+	columns := make([]string, 0)
+	for _, row := range rows {
+		if m, isMap := asStringMap(row); isMap {
+			for key := range m {
+				if !contains(columns, key) {
+					columns = append(columns, key)
+				}
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
@@ -0,0 +1,100 @@
+package template
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKvCsvToMap(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected map[string]interface{}
+	}{
+		{"kafka.controller=TRACE,kafka.request.logger=WARN", map[string]interface{}{"kafka.controller": "TRACE", "kafka.request.logger": "WARN"}},
+		{`a="x",b="y,z"`, map[string]interface{}{"a": `"x"`, "b": `"y`}},
+		{"a=b=c,d,,=e", map[string]interface{}{"a": "b=c", "": "e"}},
+		{" a = 1 ", map[string]interface{}{" a ": " 1 "}},
+		{"", map[string]interface{}{}},
+	}
+	for _, test := range tests {
+		if result := kvCsvToMap(test.text); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%q: expected %v, but got %v", test.text, test.expected, result)
+		}
+	}
+}
+
+func TestKvCsvToMapWith(t *testing.T) {
+	tests := []struct {
+		options  map[string]interface{}
+		text     string
+		expected map[string]interface{}
+		err      string
+	}{
+		{map[string]interface{}{"quote": `"`}, `a="x",b="y,z",c="say ""hi"""`, map[string]interface{}{"a": "x", "b": "y,z", "c": `say "hi"`}, ""},
+		{map[string]interface{}{"escape": `\`}, `a=x\,y,b\=c=d`, map[string]interface{}{"a": "x,y", "b=c": "d"}, ""},
+		{map[string]interface{}{"separator": ";", "pairSeparator": ":", "trim": true}, " a : 1 ; b:2", map[string]interface{}{"a": "1", "b": "2"}, ""},
+		{map[string]interface{}{"duplicates": "list"}, "a=1,a=2,b=3", map[string]interface{}{"a": []interface{}{"1", "2"}, "b": []interface{}{"3"}}, ""},
+		{map[string]interface{}{"duplicates": "first"}, "a=1,a=2", map[string]interface{}{"a": "1"}, ""},
+		{map[string]interface{}{"duplicates": "error"}, "a=1,a=2", nil, "key a is defined more than once"},
+		{map[string]interface{}{"strict": true}, "a=1,b", nil, `entry "b" at position 4 has no "="`},
+		{map[string]interface{}{"strict": true}, "=1", nil, "empty key"},
+		{map[string]interface{}{"strict": true, "quote": `"`}, `a="x`, nil, "is not closed"},
+		{map[string]interface{}{"strict": true, "quote": `"`}, `a="x"y`, nil, "after quote"},
+		{map[string]interface{}{"separator": ""}, "a=1", nil, "separator must not be empty"},
+		{map[string]interface{}{"quotes": `"`}, "a=1", nil, "unknown option quotes"},
+	}
+	for _, test := range tests {
+		result, err := kvCsvToMapWith(test.options, test.text)
+		switch {
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%q %v: expected error containing %q, but got: %v", test.text, test.options, test.err, err)
+		case test.err == "" && err != nil:
+			t.Errorf("%q %v: unexpected error: %v", test.text, test.options, err)
+		case test.err == "" && !reflect.DeepEqual(result, test.expected):
+			t.Errorf("%q %v: expected %v, but got %v", test.text, test.options, test.expected, result)
+		}
+	}
+}
+
+func TestMapToKvCsv(t *testing.T) {
+	if text, err := mapToKvCsv(map[string]interface{}{"b": "2", "a": "x=1"}); err != nil || text != "a=x=1,b=2" {
+		t.Errorf("expected a=x=1,b=2, but got %q (%v)", text, err)
+	}
+	if _, err := mapToKvCsv(map[string]interface{}{"roles": "read,write"}); err == nil {
+		t.Error("expected an error for a value with separator")
+	}
+	text, err := mapToKvCsvWith(map[string]interface{}{"quote": `"`}, map[string]interface{}{"user": "admin", "roles": "read,write"})
+	if err != nil || text != `roles="read,write",user=admin` {
+		t.Errorf(`expected roles="read,write",user=admin, but got %q (%v)`, text, err)
+	}
+	parsed, err := kvCsvToMapWith(map[string]interface{}{"quote": `"`}, text)
+	if err != nil || !reflect.DeepEqual(parsed, map[string]interface{}{"user": "admin", "roles": "read,write"}) {
+		t.Errorf("expected the map to be read back, but got %v (%v)", parsed, err)
+	}
+	if text, err := mapToKvCsvWith(map[string]interface{}{"escape": `\`}, map[string]interface{}{"a,b": "c"}); err != nil || text != `a\,b=c` {
+		t.Errorf(`expected a\,b=c, but got %q (%v)`, text, err)
+	}
+}
+
+func TestEnvMap(t *testing.T) {
+	t.Setenv("GODUB_TEST_LOGGERS", " kafka.controller = TRACE ,, kafka.request.logger=WARN,")
+	result, err := envMap("GODUB_TEST_LOGGERS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]interface{}{"kafka.controller": "TRACE", "kafka.request.logger": "WARN"}; !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, but got %v", expected, result)
+	}
+
+	t.Setenv("GODUB_TEST_LOGGERS", "kafka.controller=TRACE,WARN")
+	if _, err := envMap("GODUB_TEST_LOGGERS"); err == nil || !strings.Contains(err.Error(), "environment variable GODUB_TEST_LOGGERS must be a list of key=value pairs") {
+		t.Errorf("expected an error for an entry without '=', but got: %v", err)
+	}
+
+	t.Setenv("GODUB_TEST_LOGGERS", "")
+	result, err = envMap("GODUB_TEST_LOGGERS", "a=1")
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"a": "1"}) {
+		t.Errorf("expected the default, but got %v (%v)", result, err)
+	}
+}
@@ -0,0 +1,230 @@
+package template
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// listText returns the text of a list element, which is a string, fmt.Stringer, []byte or error.
+func listText(v interface{}) (string, bool) {
+	switch t := v.(type) {
+	case string:
+		return t, true
+	case fmt.Stringer:
+		return t.String(), true
+	case []byte:
+		return string(t), true
+	case error:
+		return t.Error(), true
+	default:
+		return "", false
+	}
+}
+
+// toListElements flattens a slice or array (also nested) into its elements and skips nil values.
+// A single value is a list with one element.
+func toListElements(list interface{}) []interface{} {
+	elements := make([]interface{}, 0)
+	if list == nil {
+		return elements
+	}
+	if _, isText := listText(list); !isText {
+		switch val := reflect.ValueOf(list); val.Kind() {
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < val.Len(); i++ {
+				elements = append(elements, toListElements(val.Index(i).Interface())...)
+			}
+			return elements
+		}
+	}
+	return append(elements, list)
+}
+
+// filterList returns the elements of a list for which keep returns the expected result. The elements
+// must be strings, fmt.Stringers, []bytes or errors, and keep their type.
+func filterList(list interface{}, expected bool, keep func(text string) bool) ([]interface{}, error) {
+	filtered := make([]interface{}, 0)
+	for _, element := range toListElements(list) {
+		text, isText := listText(element)
+		if !isText {
+			return nil, fmt.Errorf("should be type of slice, array, string, fmt.Stringer, []byte or error, but %T", element)
+		}
+		if keep(text) == expected {
+			filtered = append(filtered, element)
+		}
+	}
+	return filtered, nil
+}
+
+// filterHasPrefix returns the elements of a list which start with the prefix. Nested lists are flattened
+// and nil values are skipped.
+func filterHasPrefix(prefix string, list interface{}) ([]interface{}, error) {
+	return filterList(list, true, func(text string) bool { return strings.HasPrefix(text, prefix) })
+}
+
+// filterHasSuffix returns the elements of a list which end with the suffix.
+func filterHasSuffix(suffix string, list interface{}) ([]interface{}, error) {
+	return filterList(list, true, func(text string) bool { return strings.HasSuffix(text, suffix) })
+}
+
+// filterContains returns the elements of a list which contain the substring.
+func filterContains(substr string, list interface{}) ([]interface{}, error) {
+	return filterList(list, true, func(text string) bool { return strings.Contains(text, substr) })
+}
+
+// filterMatch returns the elements of a list which contain a match of the regular expression.
+//
+//	{{ env "KAFKA_LISTENERS" | splitList "," | filterMatch "^SASL_" }}
+func filterMatch(regex string, list interface{}) ([]interface{}, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+	return filterList(list, true, re.MatchString)
+}
+
+// rejectHasPrefix returns the elements of a list which do not start with the prefix.
+func rejectHasPrefix(prefix string, list interface{}) ([]interface{}, error) {
+	return filterList(list, false, func(text string) bool { return strings.HasPrefix(text, prefix) })
+}
+
+// rejectHasSuffix returns the elements of a list which do not end with the suffix.
+func rejectHasSuffix(suffix string, list interface{}) ([]interface{}, error) {
+	return filterList(list, false, func(text string) bool { return strings.HasSuffix(text, suffix) })
+}
+
+// rejectContains returns the elements of a list which do not contain the substring.
+func rejectContains(substr string, list interface{}) ([]interface{}, error) {
+	return filterList(list, false, func(text string) bool { return strings.Contains(text, substr) })
+}
+
+// rejectMatch returns the elements of a list which contain no match of the regular expression.
+func rejectMatch(regex string, list interface{}) ([]interface{}, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+	return filterList(list, false, re.MatchString)
+}
+
+// partition splits a list into the elements which match a predicate and the others, returned as
+// dict with the keys matched and rejected. The predicate is one of hasPrefix, hasSuffix, contains
+// and match (regular expression).
+//
+//	{{ $protocols := partition "hasPrefix" "SASL_" (env "PROTOCOLS" | splitList ",") }}
+//	{{ $protocols.matched }} {{ $protocols.rejected }}
+func partition(predicate string, arg string, list interface{}) (map[string]interface{}, error) {
+	var matches func(text string) bool
+	switch predicate {
+	case "hasPrefix":
+		matches = func(text string) bool { return strings.HasPrefix(text, arg) }
+	case "hasSuffix":
+		matches = func(text string) bool { return strings.HasSuffix(text, arg) }
+	case "contains":
+		matches = func(text string) bool { return strings.Contains(text, arg) }
+	case "match":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, err
+		}
+		matches = re.MatchString
+	default:
+		return nil, fmt.Errorf("unknown predicate %s, supported are [hasPrefix, hasSuffix, contains, match]", predicate)
+	}
+	matched, err := filterList(list, true, matches)
+	if err != nil {
+		return nil, err
+	}
+	rejected, err := filterList(list, false, matches)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"matched": matched, "rejected": rejected}, nil
+}
+
+// uniq removes duplicates of a list and keeps the order of the first occurrences. Strings,
+// fmt.Stringers, []bytes and errors are equal, if their text is equal.
+func uniq(list interface{}) []interface{} {
+	unique := make([]interface{}, 0)
+	texts := make(map[string]bool)
+	for _, element := range toListElements(list) {
+		if text, isText := listText(element); isText {
+			if !texts[text] {
+				texts[text] = true
+				unique = append(unique, element)
+			}
+		} else if !listContains(unique, element) {
+			unique = append(unique, element)
+		}
+	}
+	return unique
+}
+
+// sortBy sorts a list of maps or structs by a field (dotted for nested fields). Numbers are compared
+// numerically, all other values as text. Elements without the field come first. The sort is stable.
+//
+//	{{ range sortBy "Port" (kafkaListeners $listeners $protocolMap) }}...{{ end }}
+func sortBy(field string, list interface{}) ([]interface{}, error) {
+	elements := toListElements(list)
+	keys := make([]interface{}, len(elements))
+	for i, element := range elements {
+		switch reflect.Indirect(reflect.ValueOf(element)).Kind() {
+		case reflect.Map, reflect.Struct:
+			keys[i] = fieldValue(element, strings.Split(field, "."))
+		default:
+			return nil, fmt.Errorf("sortBy requires a list of maps or structs, but contains %T", element)
+		}
+	}
+	indexes := make([]int, len(elements))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		return lessValue(keys[indexes[a]], keys[indexes[b]])
+	})
+	sorted := make([]interface{}, len(elements))
+	for i, index := range indexes {
+		sorted[i] = elements[index]
+	}
+	return sorted, nil
+}
+
+// fieldValue returns the value of a nested field of maps and structs, or nil if it does not exist.
+func fieldValue(value interface{}, path []string) interface{} {
+	for _, name := range path {
+		val := reflect.ValueOf(value)
+		for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+			val = val.Elem()
+		}
+		switch val.Kind() {
+		case reflect.Map:
+			m, _ := asStringMap(val.Interface())
+			value = m[name]
+		case reflect.Struct:
+			field := val.FieldByName(name)
+			if !field.IsValid() || !field.CanInterface() {
+				return nil
+			}
+			value = field.Interface()
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+func lessValue(a interface{}, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b != nil
+	}
+	aNumber, aErr := strconv.ParseFloat(strval(a), 64)
+	bNumber, bErr := strconv.ParseFloat(strval(b), 64)
+	if aErr == nil && bErr == nil {
+		return aNumber < bNumber
+	}
+	return strval(a) < strval(b)
+}
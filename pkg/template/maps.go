@@ -0,0 +1,511 @@
+package template
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// List strategies of deepMergeWith.
+const (
+	listsReplace string = "replace"
+	listsAppend  string = "append"
+	listsPrepend string = "prepend"
+	listsUnion   string = "union"
+	listsIndex   string = "index"
+	listsKeep    string = "keep"
+	listsScalars string = "scalars" // keeps lists of scalars and indexes the others, used by Flatten
+)
+
+var mergeListStrategies = []string{listsReplace, listsAppend, listsPrepend, listsUnion, listsIndex}
+
+// Flatten converts nested maps into a single map, whose keys are the paths joined by the separator
+// (e.g. {"log": {"dirs": "/data"}} into {"log.dirs": "/data"}). Lists of scalars and scalars are kept as values.
+// Lists which contain maps or lists are flattened with their indexes as keys (e.g. brokers.0.host).
+// A value, which is not a map, is returned as it is.
+func Flatten(value interface{}, separator string) interface{} {
+	data, isMap := asStringMap(value)
+	if !isMap {
+		return value
+	}
+	flat := make(map[string]interface{})
+	flattenInto(flat, "", data, separator, listsScalars)
+	return flat
+}
+
+func flattenInto(flat map[string]interface{}, prefix string, value interface{}, separator string, lists string) {
+	joinKey := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + separator + key
+	}
+	if data, isMap := asStringMap(value); isMap && (len(data) > 0 || prefix == "") {
+		for key, child := range data {
+			flattenInto(flat, joinKey(key), child, separator, lists)
+		}
+		return
+	}
+	if list := reflect.ValueOf(value); list.Kind() == reflect.Slice && list.Len() > 0 &&
+		(lists == listsIndex || (lists == listsScalars && !isScalarList(list))) {
+		for i := 0; i < list.Len(); i++ {
+			flattenInto(flat, joinKey(strconv.Itoa(i)), list.Index(i).Interface(), separator, lists)
+		}
+		return
+	}
+	flat[prefix] = value
+}
+
+// isScalarList returns true, if no element of the list is a map or a list.
+func isScalarList(list reflect.Value) bool {
+	for i := 0; i < list.Len(); i++ {
+		if _, isMap := asStringMap(list.Index(i).Interface()); isMap {
+			return false
+		}
+		if kind := reflect.ValueOf(list.Index(i).Interface()).Kind(); kind == reflect.Slice || kind == reflect.Array {
+			return false
+		}
+	}
+	return true
+}
+
+// mapValueOf returns the reflect value of a map, which is the content of an OrderedMap, so that functions
+// which iterate over maps also accept OrderedMaps, like those returned by envToProp.
+func mapValueOf(value interface{}) reflect.Value {
+	if ordered, isOrdered := value.(*OrderedMap); isOrdered {
+		return reflect.ValueOf(ordered.ToMap())
+	}
+	return reflect.ValueOf(value)
+}
+
+// asStringMap returns a map with string keys for any map or OrderedMap.
+func asStringMap(value interface{}) (map[string]interface{}, bool) {
+	switch t := value.(type) {
+	case map[string]interface{}:
+		return t, true
+	case *OrderedMap:
+		return t.ToMap(), true
+	}
+	val := reflect.ValueOf(value)
+	if val.Kind() != reflect.Map {
+		return nil, false
+	}
+	data := make(map[string]interface{}, val.Len())
+	iter := val.MapRange()
+	for iter.Next() {
+		data[strval(iter.Key().Interface())] = iter.Value().Interface()
+	}
+	return data, true
+}
+
+// flattenOptions are the options of flattenWith and unflattenWith.
+type flattenOptions struct {
+	separator string
+	lists     string
+}
+
+func parseFlattenOptions(options interface{}) (flattenOptions, error) {
+	parsed := flattenOptions{separator: ".", lists: listsKeep}
+	optionsVal := reflect.ValueOf(options)
+	if optionsVal.Kind() != reflect.Map {
+		return parsed, fmt.Errorf("options must be a dict but was %T", options)
+	}
+	iter := optionsVal.MapRange()
+	for iter.Next() {
+		key := strval(iter.Key().Interface())
+		value := strval(iter.Value().Interface())
+		switch key {
+		case "separator":
+			if value == "" {
+				return parsed, fmt.Errorf("separator must not be empty")
+			}
+			parsed.separator = value
+		case "lists":
+			if value != listsKeep && value != listsIndex {
+				return parsed, fmt.Errorf("lists must be one of [%s, %s], but was: %s", listsKeep, listsIndex, value)
+			}
+			parsed.lists = value
+		default:
+			return parsed, fmt.Errorf("unknown option %s, supported are [separator, lists]", key)
+		}
+	}
+	return parsed, nil
+}
+
+// flatten converts nested maps into a map with dotted keys, e.g. for properties.
+//
+//	{{ .Values.kafka | flatten | toProperties }}
+func flatten(data interface{}) (map[string]interface{}, error) {
+	return flattenWith(map[string]interface{}{}, data)
+}
+
+// flattenWith is flatten with options, passed as dict, e.g.
+//
+//	{{ flattenWith (dict "separator" "_" "lists" "index") .Values }}
+//
+// Supported options are separator (default '.') and lists, which keeps lists as values ('keep', default)
+// or flattens them with their indexes as keys ('index').
+func flattenWith(options interface{}, data interface{}) (map[string]interface{}, error) {
+	opts, err := parseFlattenOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	nested, isMap := asStringMap(data)
+	if !isMap {
+		return nil, fmt.Errorf("flatten requires a map, but was %T", data)
+	}
+	flat := make(map[string]interface{})
+	flattenInto(flat, "", nested, opts.separator, opts.lists)
+	return flat, nil
+}
+
+// unflatten converts a map with dotted keys into nested maps, e.g. from properties.
+//
+//	{{ fromProperties $text | unflatten | toYAML }}
+func unflatten(data interface{}) (map[string]interface{}, error) {
+	return unflattenWith(map[string]interface{}{}, data)
+}
+
+// unflattenWith is unflatten with options, passed as dict. Supported options are separator (default '.')
+// and lists, which keeps numeric keys as map keys ('keep', default) or converts maps with the keys 0 to n-1
+// into lists ('index').
+func unflattenWith(options interface{}, data interface{}) (map[string]interface{}, error) {
+	opts, err := parseFlattenOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	flat, isMap := asStringMap(data)
+	if !isMap {
+		return nil, fmt.Errorf("unflatten requires a map, but was %T", data)
+	}
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	tree := make(map[string]interface{})
+	for _, key := range keys {
+		if err := setTreeValue(tree, strings.Split(key, opts.separator), flat[key]); err != nil {
+			return nil, fmt.Errorf("%s %v", key, err)
+		}
+	}
+	if opts.lists == listsIndex {
+		converted, err := treeToLists(tree, "")
+		if err != nil {
+			return nil, err
+		}
+		return converted.(map[string]interface{}), nil
+	}
+	return tree, nil
+}
+
+// mergeOptions are the options of deepMergeWith.
+type mergeOptions struct {
+	lists string
+}
+
+func parseMergeOptions(options interface{}) (mergeOptions, error) {
+	parsed := mergeOptions{lists: listsReplace}
+	optionsVal := reflect.ValueOf(options)
+	if optionsVal.Kind() != reflect.Map {
+		return parsed, fmt.Errorf("options must be a dict but was %T", options)
+	}
+	iter := optionsVal.MapRange()
+	for iter.Next() {
+		key := strval(iter.Key().Interface())
+		value := strval(iter.Value().Interface())
+		switch key {
+		case "lists":
+			if !contains(mergeListStrategies, value) {
+				return parsed, fmt.Errorf("lists must be one of %v, but was: %s", mergeListStrategies, value)
+			}
+			parsed.lists = value
+		default:
+			return parsed, fmt.Errorf("unknown option %s, supported are [lists]", key)
+		}
+	}
+	return parsed, nil
+}
+
+// deepMerge merges maps recursively into a new map. Values of later maps override values of earlier maps,
+// like values files, and lists are replaced. The maps are not modified.
+//
+//	{{ $config := deepMerge .Values.defaults (envToTree "APP_") }}
+func deepMerge(maps ...interface{}) (map[string]interface{}, error) {
+	return deepMergeWith(map[string]interface{}{}, maps...)
+}
+
+// deepMergeWith is deepMerge with options, passed as dict, e.g.
+//
+//	{{ deepMergeWith (dict "lists" "append") .Values.defaults .Values.overrides }}
+//
+// The option lists defines how lists are merged: 'replace' (default), 'append', 'prepend', 'union'
+// (appends values which are not yet contained) or 'index' (merges the values with the same index).
+func deepMergeWith(options interface{}, maps ...interface{}) (map[string]interface{}, error) {
+	opts, err := parseMergeOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[string]interface{})
+	for i, m := range maps {
+		if m == nil {
+			continue
+		}
+		src, isMap := asStringMap(m)
+		if !isMap {
+			return nil, fmt.Errorf("argument %d must be a map, but was %T", i+1, m)
+		}
+		merged = mergeValues(merged, src, opts).(map[string]interface{})
+	}
+	return merged, nil
+}
+
+func mergeValues(dst interface{}, src interface{}, opts mergeOptions) interface{} {
+	dstMap, dstIsMap := asStringMap(dst)
+	srcMap, srcIsMap := asStringMap(src)
+	if dstIsMap && srcIsMap {
+		merged := copyValue(dstMap).(map[string]interface{})
+		for key, value := range srcMap {
+			merged[key] = mergeValues(merged[key], value, opts)
+		}
+		return merged
+	}
+	dstList, dstIsList := asList(dst)
+	srcList, srcIsList := asList(src)
+	if !dstIsList || !srcIsList {
+		return copyValue(src)
+	}
+	switch opts.lists {
+	case listsAppend:
+		return copyValue(append(append([]interface{}{}, dstList...), srcList...))
+	case listsPrepend:
+		return copyValue(append(append([]interface{}{}, srcList...), dstList...))
+	case listsUnion:
+		merged := append([]interface{}{}, dstList...)
+		for _, value := range srcList {
+			if !listContains(merged, value) {
+				merged = append(merged, value)
+			}
+		}
+		return copyValue(merged)
+	case listsIndex:
+		merged := copyValue(dstList).([]interface{})
+		for i, value := range srcList {
+			if i < len(merged) {
+				merged[i] = mergeValues(merged[i], value, opts)
+			} else {
+				merged = append(merged, copyValue(value))
+			}
+		}
+		return merged
+	default:
+		return copyValue(src)
+	}
+}
+
+// copyValue copies nested maps and lists, so that the result can be modified without side effects.
+func copyValue(value interface{}) interface{} {
+	if m, isMap := asStringMap(value); isMap {
+		copied := make(map[string]interface{}, len(m))
+		for key, child := range m {
+			copied[key] = copyValue(child)
+		}
+		return copied
+	}
+	if list, isList := asList(value); isList {
+		copied := make([]interface{}, len(list))
+		for i, child := range list {
+			copied[i] = copyValue(child)
+		}
+		return copied
+	}
+	return value
+}
+
+func asList(value interface{}) ([]interface{}, bool) {
+	if list, isList := value.([]interface{}); isList {
+		return list, true
+	}
+	val := reflect.ValueOf(value)
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return nil, false
+	}
+	list := make([]interface{}, val.Len())
+	for i := range list {
+		list[i] = val.Index(i).Interface()
+	}
+	return list, true
+}
+
+func listContains(list []interface{}, value interface{}) bool {
+	for _, v := range list {
+		if jsonEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// getPath returns the value at a dotted path (a.b.0) or JSON pointer (/a/b/0) of nested maps and lists,
+// or nil if it does not exist.
+//
+//	{{ getPath "kafka.brokers.0.host" .Values | default "localhost" }}
+func getPath(path string, data interface{}) interface{} {
+	value := data
+	for _, segment := range ParsePath(path) {
+		if m, isMap := asStringMap(value); isMap {
+			child, exists := m[segment]
+			if !exists {
+				return nil
+			}
+			value = child
+		} else if list, isList := asList(value); isList {
+			index, err := listIndex(segment, len(list), false)
+			if err != nil {
+				return nil
+			}
+			value = list[index]
+		} else {
+			return nil
+		}
+	}
+	return value
+}
+
+// setPath sets the value at a dotted path or JSON pointer and returns the map. Like set of Sprig, the map
+// is modified. Missing maps are created, and an index equal to the length of a list appends the value.
+//
+//	{{ $_ := setPath "kafka.listeners.0.port" 9092 .Values }}
+func setPath(path string, value interface{}, data interface{}) (interface{}, error) {
+	segments := ParsePath(path)
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("path must not be empty")
+	}
+	if _, isMap := data.(map[string]interface{}); !isMap {
+		return nil, fmt.Errorf("setPath requires a map[string]interface{}, but was %T", data)
+	}
+	return setIn(data, segments, value, path)
+}
+
+func setIn(node interface{}, segments []string, value interface{}, path string) (interface{}, error) {
+	if len(segments) == 0 {
+		return value, nil
+	}
+	switch t := node.(type) {
+	case map[string]interface{}:
+		child, err := setIn(t[segments[0]], segments[1:], value, path)
+		if err != nil {
+			return nil, err
+		}
+		t[segments[0]] = child
+		return t, nil
+	case []interface{}:
+		index, err := listIndex(segments[0], len(t), true)
+		if err != nil {
+			return nil, fmt.Errorf("could not set %s: %v", path, err)
+		}
+		if index == len(t) {
+			t = append(t, nil)
+		}
+		child, err := setIn(t[index], segments[1:], value, path)
+		if err != nil {
+			return nil, err
+		}
+		t[index] = child
+		return t, nil
+	case nil:
+		return setIn(map[string]interface{}{}, segments, value, path)
+	default:
+		return nil, fmt.Errorf("could not set %s, because %s is a %T", path, segments[0], node)
+	}
+}
+
+// deletePath deletes the value at a dotted path or JSON pointer and returns the map. Like unset of Sprig,
+// the map is modified. Missing paths are ignored.
+func deletePath(path string, data interface{}) (interface{}, error) {
+	segments := ParsePath(path)
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("path must not be empty")
+	}
+	if _, isMap := data.(map[string]interface{}); !isMap {
+		return nil, fmt.Errorf("deletePath requires a map[string]interface{}, but was %T", data)
+	}
+	return deleteIn(data, segments), nil
+}
+
+func deleteIn(node interface{}, segments []string) interface{} {
+	switch t := node.(type) {
+	case map[string]interface{}:
+		if child, exists := t[segments[0]]; exists {
+			if len(segments) == 1 {
+				delete(t, segments[0])
+			} else {
+				t[segments[0]] = deleteIn(child, segments[1:])
+			}
+		}
+		return t
+	case []interface{}:
+		index, err := listIndex(segments[0], len(t), false)
+		if err != nil {
+			return t
+		}
+		if len(segments) == 1 {
+			return append(append([]interface{}{}, t[:index]...), t[index+1:]...)
+		}
+		t[index] = deleteIn(t[index], segments[1:])
+		return t
+	default:
+		return node
+	}
+}
+
+// listIndex parses an index of a list. Negative indexes count from the end.
+func listIndex(segment string, length int, allowAppend bool) (int, error) {
+	index, err := strconv.Atoi(segment)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not an index of a list", segment)
+	}
+	if index < 0 {
+		index += length
+	}
+	if index < 0 || index > length || (index == length && !allowAppend) {
+		return 0, fmt.Errorf("index %s is out of range of a list with %d values", segment, length)
+	}
+	return index, nil
+}
+
+// mapDiff compares two maps and returns the dotted keys of nested values which were added, removed
+// and changed. Changed keys have the old and the new value.
+//
+//	{{ range $key, $change := (mapDiff .Values.defaults $config).changed }}
+//	# {{ $key }} changed from {{ $change.old }} to {{ $change.new }}
+//	{{ end }}
+func mapDiff(old interface{}, new interface{}) (map[string]interface{}, error) {
+	oldFlat, err := flatten(old)
+	if err != nil {
+		return nil, err
+	}
+	newFlat, err := flatten(new)
+	if err != nil {
+		return nil, err
+	}
+	added := make(map[string]interface{})
+	removed := make(map[string]interface{})
+	changed := make(map[string]interface{})
+	for key, oldValue := range oldFlat {
+		newValue, exists := newFlat[key]
+		if !exists {
+			removed[key] = oldValue
+		} else if !jsonEqual(oldValue, newValue) {
+			changed[key] = map[string]interface{}{"old": oldValue, "new": newValue}
+		}
+	}
+	for key, newValue := range newFlat {
+		if _, exists := oldFlat[key]; !exists {
+			added[key] = newValue
+		}
+	}
+	return map[string]interface{}{"added": added, "removed": removed, "changed": changed}, nil
+}
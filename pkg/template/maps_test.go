@@ -0,0 +1,42 @@
+package template
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlatten(t *testing.T) {
+	data := map[string]interface{}{
+		"log":       map[string]interface{}{"dirs": "/data"},
+		"listeners": []interface{}{"PLAINTEXT://:9092", "CONTROLLER://:9093"},
+		"brokers": []interface{}{
+			map[string]interface{}{"host": "kafka-0", "ports": []interface{}{9092, 9093}},
+			map[string]interface{}{"host": "kafka-1", "racks": []interface{}{map[string]interface{}{"id": "a"}}},
+		},
+		"matrix": []interface{}{[]interface{}{1, 2}},
+	}
+	expected := map[string]interface{}{
+		"log.dirs":             "/data",
+		"listeners":            []interface{}{"PLAINTEXT://:9092", "CONTROLLER://:9093"},
+		"brokers.0.host":       "kafka-0",
+		"brokers.0.ports":      []interface{}{9092, 9093},
+		"brokers.1.host":       "kafka-1",
+		"brokers.1.racks.0.id": "a",
+		"matrix.0":             []interface{}{1, 2},
+	}
+	if flat := Flatten(data, "."); !reflect.DeepEqual(flat, expected) {
+		t.Errorf("expected %v, but got %v", expected, flat)
+	}
+	if value := Flatten([]interface{}{1}, "."); !reflect.DeepEqual(value, []interface{}{1}) {
+		t.Errorf("expected a list to be returned as it is, but got %v", value)
+	}
+
+	text, err := Encode(FormatProperties, Flatten(data, "."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "brokers.0.host = kafka-0\nbrokers.0.ports = 9092,9093\nbrokers.1.host = kafka-1\nbrokers.1.racks.0.id = a\n" +
+		"listeners = PLAINTEXT://:9092,CONTROLLER://:9093\nlog.dirs = /data\nmatrix.0 = 1,2\n"; text != expected {
+		t.Errorf("expected:\n%s\nbut got:\n%s", expected, text)
+	}
+}
@@ -0,0 +1,433 @@
+package template
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// InterfaceAddress is a global unicast address of a network interface.
+//
+// In templates it is rendered as plain IP address, other properties can be accessed
+// by its fields and methods, e.g. {{ .Interface }} or {{ .CIDR }}.
+type InterfaceAddress struct {
+	Interface string
+	Index     int
+	MAC       string
+	IP        net.IP
+	PrefixLen int
+}
+
+func (a InterfaceAddress) String() string {
+	return a.IP.String()
+}
+
+// CIDR returns the address with prefix length, e.g. 172.20.0.2/16.
+func (a InterfaceAddress) CIDR() string {
+	return fmt.Sprintf("%s/%d", a.IP, a.PrefixLen)
+}
+
+// Network returns the network of the address, e.g. 172.20.0.0/16.
+func (a InterfaceAddress) Network() string {
+	mask := net.CIDRMask(a.PrefixLen, len(a.IP)*8)
+	return (&net.IPNet{IP: a.IP.Mask(mask), Mask: mask}).String()
+}
+
+// Version returns either ipv4 or ipv6.
+func (a InterfaceAddress) Version() string {
+	return ipAddrVersion(a.IP)
+}
+
+// interfaceAddresses returns all global unicast addresses of all interfaces which are up,
+// in the order of the interfaces and addresses as reported by the kernel.
+func interfaceAddresses() ([]InterfaceAddress, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]InterfaceAddress, 0)
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || !ipNet.IP.IsGlobalUnicast() {
+				continue
+			}
+			ip := ipNet.IP
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			prefixLen, _ := ipNet.Mask.Size()
+			addresses = append(addresses, InterfaceAddress{
+				Interface: iface.Name,
+				Index:     iface.Index,
+				MAC:       iface.HardwareAddr.String(),
+				IP:        ip,
+				PrefixLen: prefixLen,
+			})
+		}
+	}
+	return addresses, nil
+}
+
+// onInterface keeps all addresses of interfaces with a name matching the glob pattern (e.g. eth*).
+//
+//	{{ interfaceAddresses | onInterface "eth*" | first }}
+func onInterface(pattern string, list interface{}) ([]InterfaceAddress, error) {
+	g, err := glob.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid interface pattern %s: %v", pattern, err)
+	}
+	return filterInterfaceAddresses(list, func(a InterfaceAddress) bool { return g.Match(a.Interface) })
+}
+
+// inCidr keeps all addresses which are part of at least one of the given networks.
+// The networks can be a single CIDR (e.g. 172.20.0.0/16) or a list of CIDRs.
+func inCidr(cidrs interface{}, list interface{}) ([]InterfaceAddress, error) {
+	networks, err := parseCidrs(cidrs)
+	if err != nil {
+		return nil, err
+	}
+	return filterInterfaceAddresses(list, func(a InterfaceAddress) bool { return containedInAny(networks, a.IP) })
+}
+
+// notInCidr removes all addresses which are part of at least one of the given networks.
+func notInCidr(cidrs interface{}, list interface{}) ([]InterfaceAddress, error) {
+	networks, err := parseCidrs(cidrs)
+	if err != nil {
+		return nil, err
+	}
+	return filterInterfaceAddresses(list, func(a InterfaceAddress) bool { return !containedInAny(networks, a.IP) })
+}
+
+// withIpVersion keeps all addresses of the given version (ipv4 or ipv6).
+func withIpVersion(ipVersion string, list interface{}) ([]InterfaceAddress, error) {
+	if ipVersion != ipv4 && ipVersion != ipv6 {
+		return nil, fmt.Errorf("IP version argument must be one of [%s, %s], but was: %s", ipv4, ipv6, ipVersion)
+	}
+	return filterInterfaceAddresses(list, func(a InterfaceAddress) bool { return a.Version() == ipVersion })
+}
+
+// preferInterfaces orders the addresses by the first matching interface pattern. Addresses
+// of interfaces which match none of the patterns are moved to the end. Otherwise, the order
+// is retained.
+//
+//	{{ interfaceAddresses | preferInterfaces (list "eth1" "eth*") | first }}
+func preferInterfaces(patterns interface{}, list interface{}) ([]InterfaceAddress, error) {
+	globs := make([]glob.Glob, 0)
+	for _, pattern := range toFlatListOfStrings(patterns) {
+		g, err := glob.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid interface pattern %s: %v", pattern, err)
+		}
+		globs = append(globs, g)
+	}
+	addresses, err := toInterfaceAddresses(list)
+	if err != nil {
+		return nil, err
+	}
+	priority := func(a InterfaceAddress) int {
+		for i, g := range globs {
+			if g.Match(a.Interface) {
+				return i
+			}
+		}
+		return len(globs)
+	}
+	sort.SliceStable(addresses, func(i, j int) bool { return priority(addresses[i]) < priority(addresses[j]) })
+	return addresses, nil
+}
+
+// interfaceIpAddress returns the first address of the given version (ipv4 or ipv6) of an
+// interface matching the glob pattern.
+//
+//	{{ interfaceIpAddress "eth*" "ipv4" }}
+func interfaceIpAddress(pattern string, ipVersion string) (*InterfaceAddress, error) {
+	addresses, err := interfaceAddresses()
+	if err != nil {
+		return nil, err
+	}
+	if addresses, err = onInterface(pattern, addresses); err != nil {
+		return nil, err
+	}
+	if addresses, err = withIpVersion(ipVersion, addresses); err != nil {
+		return nil, err
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no interface matching '%s' has a global unicast %s address", pattern, ipVersion)
+	}
+	return &addresses[0], nil
+}
+
+// cidrIpAddress returns the first address which is part of one of the given networks.
+//
+//	{{ cidrIpAddress "172.20.0.0/16" }}
+func cidrIpAddress(cidrs interface{}) (*InterfaceAddress, error) {
+	addresses, err := interfaceAddresses()
+	if err != nil {
+		return nil, err
+	}
+	if addresses, err = inCidr(cidrs, addresses); err != nil {
+		return nil, err
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("no interface has a global unicast address in %v", toFlatListOfStrings(cidrs))
+	}
+	return &addresses[0], nil
+}
+
+func filterInterfaceAddresses(list interface{}, keep func(InterfaceAddress) bool) ([]InterfaceAddress, error) {
+	addresses, err := toInterfaceAddresses(list)
+	if err != nil {
+		return nil, err
+	}
+	filtered := make([]InterfaceAddress, 0, len(addresses))
+	for _, address := range addresses {
+		if keep(address) {
+			filtered = append(filtered, address)
+		}
+	}
+	return filtered, nil
+}
+
+func toInterfaceAddresses(list interface{}) ([]InterfaceAddress, error) {
+	switch t := list.(type) {
+	case nil:
+		return []InterfaceAddress{}, nil
+	case []InterfaceAddress:
+		return append([]InterfaceAddress{}, t...), nil
+	case InterfaceAddress:
+		return []InterfaceAddress{t}, nil
+	case *InterfaceAddress:
+		return []InterfaceAddress{*t}, nil
+	}
+	val := reflect.ValueOf(list)
+	switch val.Kind() {
+	case reflect.Slice, reflect.Array:
+		addresses := make([]InterfaceAddress, 0, val.Len())
+		for i := 0; i < val.Len(); i++ {
+			sub, err := toInterfaceAddresses(val.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			addresses = append(addresses, sub...)
+		}
+		return addresses, nil
+	default:
+		return nil, fmt.Errorf("must be a list of interface addresses but was %T", list)
+	}
+}
+
+func parseCidrs(cidrs interface{}) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0)
+	for _, cidr := range toFlatListOfStrings(cidrs) {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func containedInAny(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Endpoint is an address with optional scheme, e.g. PLAINTEXT://kafka:9092 or [::1]:9092.
+// In templates it is rendered in the same format, with IPv6 hosts in brackets.
+type Endpoint struct {
+	Scheme string
+	Host   string
+	Port   int
+}
+
+func (e Endpoint) String() string {
+	if e.Scheme != "" {
+		return e.Scheme + "://" + e.HostPort()
+	}
+	return e.HostPort()
+}
+
+// HostPort returns host and port without scheme, e.g. [::1]:9092.
+func (e Endpoint) HostPort() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// joinHostPort combines host and port, IPv6 hosts are put in brackets.
+//
+//	{{ joinHostPort "::1" 9092 }} -> [::1]:9092
+func joinHostPort(host string, port interface{}) string {
+	return net.JoinHostPort(strings.Trim(host, "[]"), strval(port))
+}
+
+// splitHostPort splits host:port, [host]:port or scheme://host:port. The host can be empty.
+//
+//	{{ (splitHostPort "[::1]:9092").Host }} -> ::1
+func splitHostPort(text string) (*Endpoint, error) {
+	return parseEndpoint(text)
+}
+
+// parseEndpoint parses an URL-ish address like PLAINTEXT://kafka:9092 into scheme, host and port.
+// Scheme and host are optional, the port is required.
+func parseEndpoint(text string) (*Endpoint, error) {
+	endpoint := &Endpoint{}
+	hostPort := text
+	if scheme, rest, hasScheme := strings.Cut(text, "://"); hasScheme {
+		endpoint.Scheme, hostPort = scheme, rest
+	}
+	host, portText, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %s: %v", text, err)
+	}
+	port, err := strconv.ParseUint(portText, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %s: port must be a number between 0 and 65535, but was: %s", text, portText)
+	}
+	endpoint.Host, endpoint.Port = host, int(port)
+	return endpoint, nil
+}
+
+// parseEndpoints parses a comma separated list of endpoints, e.g. PLAINTEXT://:9092,SSL://:9093.
+func parseEndpoints(text string) ([]Endpoint, error) {
+	endpoints := make([]Endpoint, 0)
+	for _, part := range strings.Split(text, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		endpoint, err := parseEndpoint(part)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, *endpoint)
+	}
+	return endpoints, nil
+}
+
+// canonicalIp returns the canonical representation of an IP address. IPv4-mapped IPv6
+// addresses are returned as IPv4 and IPv6 addresses are compressed.
+//
+//	{{ canonicalIp "0:0:0:0:0:ffff:c000:0201" }} -> 192.0.2.1
+func canonicalIp(text string) (string, error) {
+	ip := net.ParseIP(strings.Trim(text, "[]"))
+	if ip == nil {
+		return "", fmt.Errorf("%s is not a valid IP address", text)
+	}
+	return ip.String(), nil
+}
+
+func isIp(text string) bool {
+	return net.ParseIP(text) != nil
+}
+
+func isIpv4(text string) bool {
+	ip := net.ParseIP(text)
+	return ip != nil && ip.To4() != nil
+}
+
+func isIpv6(text string) bool {
+	ip := net.ParseIP(text)
+	return ip != nil && ip.To4() == nil
+}
+
+// cidrContains checks if the IP address is part of the network.
+func cidrContains(cidr string, ip interface{}) (bool, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false, err
+	}
+	parsed := net.ParseIP(strval(ip))
+	if parsed == nil {
+		return false, fmt.Errorf("%s is not a valid IP address", strval(ip))
+	}
+	return network.Contains(parsed), nil
+}
+
+// cidrHost returns the n-th address of the network. A negative n counts from the end,
+// like cidrhost of Terraform.
+//
+//	{{ cidrHost "10.0.0.0/24" 5 }} -> 10.0.0.5
+//	{{ cidrHost "10.0.0.0/24" -2 }} -> 10.0.0.254
+func cidrHost(cidr string, n int) (string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	ones, bits := network.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	offset := big.NewInt(int64(n))
+	if n < 0 {
+		offset.Add(size, offset)
+	}
+	if offset.Sign() < 0 || offset.Cmp(size) >= 0 {
+		return "", fmt.Errorf("network %s has no host number %d", cidr, n)
+	}
+	address := new(big.Int).SetBytes(network.IP)
+	address.Add(address, offset)
+	return bigIntToIp(address, len(network.IP)).String(), nil
+}
+
+// cidrNetwork returns the network address, e.g. 10.0.0.0 for 10.0.0.17/24.
+func cidrNetwork(cidr string) (string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	return network.IP.String(), nil
+}
+
+// cidrNetmask returns the netmask, e.g. 255.255.255.0 for 10.0.0.0/24.
+func cidrNetmask(cidr string) (string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	return net.IP(network.Mask).String(), nil
+}
+
+// cidrBroadcast returns the last address of the network, which is the broadcast address for IPv4.
+func cidrBroadcast(cidr string) (string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	broadcast := make(net.IP, len(network.IP))
+	for i := range network.IP {
+		broadcast[i] = network.IP[i] | ^network.Mask[i]
+	}
+	return broadcast.String(), nil
+}
+
+// cidrPrefixLen returns the prefix length, e.g. 24 for 10.0.0.0/24.
+func cidrPrefixLen(cidr string) (int, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return 0, err
+	}
+	ones, _ := network.Mask.Size()
+	return ones, nil
+}
+
+func bigIntToIp(value *big.Int, length int) net.IP {
+	b := value.Bytes()
+	ip := make(net.IP, length)
+	copy(ip[length-len(b):], b)
+	return ip
+}
@@ -0,0 +1,239 @@
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/magiconair/properties"
+	"go.yaml.in/yaml/v3"
+)
+
+// OrderedMap is a map which keeps the insertion order of its keys and has an optional comment per key.
+// In templates, it is created with orderedDict or toOrderedMap, returned by envToProp and fromProperties,
+// and accessed with its methods. Functions which require a map, like merge and hasKey of Sprig, get it with ToMap.
+//
+//	{{ $props := orderedDict "node.id" 1 "process.roles" "broker" }}
+//	{{ $_ := $props.Set "log.dirs" "/var/lib/kafka" }}
+//	{{ range $props.Entries }}{{ .Key }}={{ .Value }}{{ end }}
+//
+// It is rendered in insertion order by toProperties, toJSON and toYAML.
+type OrderedMap struct {
+	keys     []string
+	values   map[string]interface{}
+	comments map[string]string
+}
+
+// OrderedMapEntry is an entry of an OrderedMap.
+type OrderedMapEntry struct {
+	Key     string
+	Value   interface{}
+	Comment string
+}
+
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{keys: make([]string, 0), values: make(map[string]interface{}), comments: make(map[string]string)}
+}
+
+// Set sets the value of a key. New keys are appended, existing keys keep their position.
+func (m *OrderedMap) Set(key string, value interface{}) *OrderedMap {
+	if _, exists := m.values[key]; !exists {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+	return m
+}
+
+// SetComment sets the comment of a key, which is written by toProperties before the key.
+func (m *OrderedMap) SetComment(key string, comment string) *OrderedMap {
+	m.comments[key] = comment
+	return m
+}
+
+func (m *OrderedMap) Get(key string) interface{} {
+	return m.values[key]
+}
+
+func (m *OrderedMap) Has(key string) bool {
+	_, exists := m.values[key]
+	return exists
+}
+
+func (m *OrderedMap) Comment(key string) string {
+	return m.comments[key]
+}
+
+func (m *OrderedMap) Delete(key string) *OrderedMap {
+	if _, exists := m.values[key]; !exists {
+		return m
+	}
+	delete(m.values, key)
+	delete(m.comments, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+	return m
+}
+
+func (m *OrderedMap) Keys() []string {
+	return append([]string{}, m.keys...)
+}
+
+func (m *OrderedMap) Values() []interface{} {
+	values := make([]interface{}, 0, len(m.keys))
+	for _, key := range m.keys {
+		values = append(values, m.values[key])
+	}
+	return values
+}
+
+func (m *OrderedMap) Entries() []OrderedMapEntry {
+	entries := make([]OrderedMapEntry, 0, len(m.keys))
+	for _, key := range m.keys {
+		entries = append(entries, OrderedMapEntry{Key: key, Value: m.values[key], Comment: m.comments[key]})
+	}
+	return entries
+}
+
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// Sorted returns a copy with keys in alphabetical order.
+func (m *OrderedMap) Sorted() *OrderedMap {
+	sorted := m.copy()
+	sort.Strings(sorted.keys)
+	return sorted
+}
+
+// ToMap returns a plain map, which can be used with functions like keys or hasKey.
+func (m *OrderedMap) ToMap() map[string]interface{} {
+	plain := make(map[string]interface{}, len(m.values))
+	for key, value := range m.values {
+		plain[key] = value
+	}
+	return plain
+}
+
+func (m *OrderedMap) copy() *OrderedMap {
+	c := NewOrderedMap()
+	for _, key := range m.keys {
+		c.Set(key, m.values[key])
+	}
+	for key, comment := range m.comments {
+		c.comments[key] = comment
+	}
+	return c
+}
+
+func (m *OrderedMap) String() string {
+	entries := make([]string, 0, len(m.keys))
+	for _, key := range m.keys {
+		entries = append(entries, fmt.Sprintf("%s:%v", key, m.values[key]))
+	}
+	return "map[" + strings.Join(entries, " ") + "]"
+}
+
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		keyJSON, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		valueJSON, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(keyJSON)
+		buf.WriteByte(':')
+		buf.Write(valueJSON)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (m *OrderedMap) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range m.keys {
+		valueNode := &yaml.Node{}
+		if err := valueNode.Encode(m.values[key]); err != nil {
+			return nil, err
+		}
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: key, HeadComment: m.comments[key]}
+		node.Content = append(node.Content, keyNode, valueNode)
+	}
+	return node, nil
+}
+
+// orderedDict creates an OrderedMap from key/value pairs, like dict.
+//
+//	{{ orderedDict "node.id" 1 "process.roles" "broker" | toProperties }}
+func orderedDict(pairs ...interface{}) (*OrderedMap, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("orderedDict requires key/value pairs, but got %d arguments", len(pairs))
+	}
+	m := NewOrderedMap()
+	for i := 0; i < len(pairs); i += 2 {
+		m.Set(strval(pairs[i]), pairs[i+1])
+	}
+	return m, nil
+}
+
+// toOrderedMap converts a map into an OrderedMap with keys in alphabetical order.
+// An OrderedMap is copied and keeps its order.
+func toOrderedMap(v interface{}) (*OrderedMap, error) {
+	if m, ok := v.(*OrderedMap); ok {
+		return m.copy(), nil
+	}
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Map {
+		return nil, fmt.Errorf("must be a map but was %T", v)
+	}
+	m := NewOrderedMap()
+	iter := val.MapRange()
+	for iter.Next() {
+		m.Set(strval(iter.Key().Interface()), iter.Value().Interface())
+	}
+	return m.Sorted(), nil
+}
+
+// fromProperties parses properties into an OrderedMap, which keeps the order and the comments of the text.
+// Values are returned as they are, without expansion of ${...}.
+//
+//	{{ $props := fromProperties (.Files.Get "server.properties") }}{{ $props.Get "log.dirs" }}
+func fromProperties(text string) (*OrderedMap, error) {
+	loader := properties.Loader{Encoding: properties.UTF8, DisableExpansion: true}
+	props, err := loader.LoadBytes([]byte(text))
+	if err != nil {
+		return nil, err
+	}
+	m := NewOrderedMap()
+	for _, key := range props.Keys() {
+		value, _ := props.Get(key)
+		m.Set(key, value)
+		if comments := props.GetComments(key); len(comments) > 0 {
+			m.SetComment(key, strings.Join(comments, "\n"))
+		}
+	}
+	return m, nil
+}
+
+// fromPropertiesToMap is fromProperties for callers which require a map, like the values of the context.
+func fromPropertiesToMap(text string) (map[string]interface{}, error) {
+	props, err := fromProperties(text)
+	if err != nil {
+		return nil, err
+	}
+	return props.ToMap(), nil
+}
@@ -0,0 +1,61 @@
+package template
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFromProperties(t *testing.T) {
+	text := "# the log directory\nlog.dirs=${kafka.logs.dir}/data\nkafka.logs.dir=/var/log\nhome=${HOME}\n"
+	ordered, err := fromProperties(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := ordered.Keys(); !reflect.DeepEqual(keys, []string{"log.dirs", "kafka.logs.dir", "home"}) {
+		t.Errorf("unexpected order of keys: %v", keys)
+	}
+	if comment := ordered.Comment("log.dirs"); comment != "the log directory" {
+		t.Errorf("unexpected comment: %q", comment)
+	}
+	unordered, err := fromPropertiesToMap(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ordered.ToMap(), unordered) {
+		t.Errorf("expected the same values as fromPropertiesToMap %v, but got %v", unordered, ordered.ToMap())
+	}
+	if value := ordered.Get("home"); value != "${HOME}" {
+		t.Errorf("expected the value without expansion, but got %v", value)
+	}
+}
+
+func TestToPropertiesExpansion(t *testing.T) {
+	t.Setenv("GODUB_TEST_HOME", "/home/kafka")
+	props := NewOrderedMap().
+		Set("log.dirs", "${kafka.logs.dir}/data").
+		Set("kafka.logs.dir", "${GODUB_TEST_HOME}/logs").
+		Set("literal", "a=b")
+
+	text, err := toProperties(props)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "log.dirs = /home/kafka/logs/data\nkafka.logs.dir = /home/kafka/logs\nliteral = a=b\n"; text != expected {
+		t.Errorf("expected %q, but got %q", expected, text)
+	}
+	if props.Get("log.dirs") != "${kafka.logs.dir}/data" {
+		t.Errorf("expected the map not to be modified, but got %v", props.Get("log.dirs"))
+	}
+
+	text, err = toPropertiesWith(map[string]interface{}{"expand": false}, props)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "log.dirs = ${kafka.logs.dir}/data\nkafka.logs.dir = ${GODUB_TEST_HOME}/logs\nliteral = a=b\n"; text != expected {
+		t.Errorf("expected %q, but got %q", expected, text)
+	}
+
+	if _, err := toProperties(map[string]interface{}{"a": "${b}", "b": "${a}"}); err == nil {
+		t.Error("expected an error for a circular reference")
+	}
+}
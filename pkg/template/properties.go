@@ -0,0 +1,156 @@
+package template
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/magiconair/properties"
+)
+
+// propertiesOptions are the options of toPropertiesWith. The defaults produce the same
+// format as toProperties.
+type propertiesOptions struct {
+	sort          bool
+	separator     string
+	escape        bool
+	escapeUnicode bool
+	comments      map[string]string
+	header        string
+	expand        bool
+}
+
+var propertiesSeparators = []string{" = ", "=", ":", " : "}
+
+func parsePropertiesOptions(options interface{}) (propertiesOptions, error) {
+	parsed := propertiesOptions{separator: " = ", comments: make(map[string]string), expand: true}
+	optionsVal := reflect.ValueOf(options)
+	if optionsVal.Kind() != reflect.Map {
+		return parsed, fmt.Errorf("options must be a dict but was %T", options)
+	}
+	iter := optionsVal.MapRange()
+	for iter.Next() {
+		key := strval(iter.Key().Interface())
+		value := iter.Value().Interface()
+		switch key {
+		case "sort", "escape", "escapeUnicode", "expand":
+			flag, err := strconv.ParseBool(strval(value))
+			if err != nil {
+				return parsed, fmt.Errorf("%s must be a bool, but was: %v", key, value)
+			}
+			switch key {
+			case "sort":
+				parsed.sort = flag
+			case "escape":
+				parsed.escape = flag
+			case "expand":
+				parsed.expand = flag
+			default:
+				parsed.escapeUnicode = flag
+			}
+		case "separator":
+			if !contains(propertiesSeparators, strval(value)) {
+				return parsed, fmt.Errorf("separator must be one of %q, but was: %q", propertiesSeparators, strval(value))
+			}
+			parsed.separator = strval(value)
+		case "comments":
+			comments := reflect.ValueOf(value)
+			if comments.Kind() != reflect.Map {
+				return parsed, fmt.Errorf("comments must be a dict but was %T", value)
+			}
+			commentsIter := comments.MapRange()
+			for commentsIter.Next() {
+				parsed.comments[strval(commentsIter.Key().Interface())] = strval(commentsIter.Value().Interface())
+			}
+		case "header":
+			parsed.header = strval(value)
+		default:
+			return parsed, fmt.Errorf("unknown option %s, supported are [sort, separator, escape, escapeUnicode, expand, comments, header]", key)
+		}
+	}
+	return parsed, nil
+}
+
+func writeProperties(props *OrderedMap, opts propertiesOptions) string {
+	var b strings.Builder
+	if opts.header != "" {
+		writePropertiesComment(&b, opts.header)
+		b.WriteString("\n")
+	}
+	for _, entry := range props.Entries() {
+		comment := entry.Comment
+		if override, ok := opts.comments[entry.Key]; ok {
+			comment = override
+		}
+		if comment != "" {
+			writePropertiesComment(&b, comment)
+		}
+		key, value := entry.Key, strval(entry.Value)
+		if opts.escape || opts.escapeUnicode {
+			key = escapeProperties(key, true, opts.escape, opts.escapeUnicode)
+			value = escapeProperties(value, false, opts.escape, opts.escapeUnicode)
+		}
+		b.WriteString(key + opts.separator + value + "\n")
+	}
+	return b.String()
+}
+
+func writePropertiesComment(b *strings.Builder, comment string) {
+	for _, line := range strings.Split(comment, "\n") {
+		b.WriteString(strings.TrimRight("# "+line, " ") + "\n")
+	}
+}
+
+// escapeProperties escapes a key or value as defined by java.util.Properties. With special, backslashes,
+// control characters, separators and comment characters in keys and leading spaces of values are
+// escaped. With unicode, characters outside of printable ASCII are written as \uXXXX.
+func escapeProperties(text string, isKey bool, special bool, unicode bool) string {
+	var b strings.Builder
+	for i, r := range text {
+		switch {
+		case special && r == '\\':
+			b.WriteString(`\\`)
+		case special && r == '\n':
+			b.WriteString(`\n`)
+		case special && r == '\r':
+			b.WriteString(`\r`)
+		case special && r == '\t':
+			b.WriteString(`\t`)
+		case special && r == '\f':
+			b.WriteString(`\f`)
+		case special && isKey && (r == ' ' || r == '=' || r == ':'):
+			b.WriteString(`\` + string(r))
+		case special && (r == '#' || r == '!') && (isKey || i == 0):
+			b.WriteString(`\` + string(r))
+		case special && !isKey && r == ' ' && strings.TrimLeft(text[:i], " ") == "":
+			b.WriteString(`\ `)
+		case unicode && (r < 0x20 || r > 0x7e):
+			if r > 0xffff {
+				r -= 0x10000
+				fmt.Fprintf(&b, `\u%04x\u%04x`, 0xd800+(r>>10), 0xdc00+(r&0x3ff))
+			} else {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// expandProperties resolves references like ${key} in the values with other properties and environment
+// variables, as the properties library does. Circular references and malformed expressions are an error.
+func expandProperties(props *OrderedMap) error {
+	resolver := properties.NewProperties()
+	for _, entry := range props.Entries() {
+		if _, _, err := resolver.Set(entry.Key, strval(entry.Value)); err != nil {
+			return fmt.Errorf("could not expand property %s: %v", entry.Key, err)
+		}
+	}
+	for _, key := range props.Keys() {
+		value, _ := resolver.Get(key)
+		props.Set(key, value)
+	}
+	return nil
+}
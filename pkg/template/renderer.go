@@ -50,7 +50,11 @@ func (r *Renderer) Render() (err error) {
 		return
 	}
 
-	err = waitUntilDone(fanOutSink(r.toFuncs...)(transformerAnyOrder(renderer(engine, context))(mergeSourcesAnyOrder(r.fromFuncs...)())))
+	rendered := transformerAnyOrder(renderer(engine, context))(mergeSourcesAnyOrder(r.fromFuncs...)())
+	if r.config.CollectErrors {
+		rendered = holdBackOnError(rendered)
+	}
+	err = waitUntilDone(fanOutSink(r.toFuncs...)(rendered))
 
 	return
 }
@@ -0,0 +1,327 @@
+package template
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// Route table files of the kernel. They are variables, so that they can be replaced by fixtures.
+var (
+	procNetRoute     = "/proc/net/route"
+	procNetIpv6Route = "/proc/net/ipv6_route"
+)
+
+// Route flags as defined in linux/route.h and linux/ipv6_route.h.
+const (
+	routeFlagUp      uint32 = 0x0001
+	routeFlagGateway uint32 = 0x0002
+	routeFlagReject  uint32 = 0x0200
+)
+
+// nativeEndian is the byte order in which /proc/net/route contains IPv4 addresses.
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// Route is an entry of the kernel routing table.
+//
+// In templates it is rendered similar to the output of 'ip route', e.g. 'default via 172.20.0.1 dev eth0'.
+type Route struct {
+	Interface   string
+	Destination net.IPNet
+	Gateway     net.IP
+	Metric      uint32
+	Flags       uint32
+}
+
+func (r Route) String() string {
+	var b strings.Builder
+	if r.IsDefault() {
+		b.WriteString("default")
+	} else {
+		b.WriteString(r.Destination.String())
+	}
+	if r.HasGateway() {
+		fmt.Fprintf(&b, " via %s", r.Gateway)
+	}
+	fmt.Fprintf(&b, " dev %s", r.Interface)
+	return b.String()
+}
+
+// IsDefault checks if this is a default route (0.0.0.0/0 or ::/0).
+func (r Route) IsDefault() bool {
+	ones, _ := r.Destination.Mask.Size()
+	return ones == 0
+}
+
+// HasGateway checks if the destination is reached via a gateway.
+func (r Route) HasGateway() bool {
+	return r.Flags&routeFlagGateway != 0 && r.Gateway != nil && !r.Gateway.IsUnspecified()
+}
+
+// Version returns either ipv4 or ipv6.
+func (r Route) Version() string {
+	return ipAddrVersion(r.Destination.IP)
+}
+
+// routes returns all usable routes of the given IP version (ipv4 or ipv6).
+func routes(ipVersion string) ([]Route, error) {
+	var filename string
+	var parse func(io.Reader) ([]Route, error)
+	switch ipVersion {
+	case ipv4:
+		filename, parse = procNetRoute, parseIpv4Routes
+	case ipv6:
+		filename, parse = procNetIpv6Route, parseIpv6Routes
+	default:
+		return nil, fmt.Errorf("IP version argument must be one of [%s, %s], but was: %s", ipv4, ipv6, ipVersion)
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not read routes: %v", err)
+	}
+	defer file.Close()
+	return parse(file)
+}
+
+// parseIpv4Routes parses the format of /proc/net/route. Addresses are hex encoded in native byte order.
+//
+//	Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+//	eth0	00000000	010014AC	0003	0	0	0	00000000	0	0	0
+func parseIpv4Routes(reader io.Reader) ([]Route, error) {
+	result := make([]Route, 0)
+	scanner := bufio.NewScanner(reader)
+	for line := 0; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if line == 0 || len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 8 {
+			return nil, fmt.Errorf("invalid route in line %d: %s", line+1, scanner.Text())
+		}
+		destination, err1 := parseIpv4RouteAddress(fields[1])
+		gateway, err2 := parseIpv4RouteAddress(fields[2])
+		flags, err3 := strconv.ParseUint(fields[3], 16, 32)
+		metric, err4 := strconv.ParseUint(fields[6], 10, 32)
+		mask, err5 := parseIpv4RouteAddress(fields[7])
+		if err := firstError(err1, err2, err3, err4, err5); err != nil {
+			return nil, fmt.Errorf("invalid route in line %d: %v", line+1, err)
+		}
+		route := Route{
+			Interface:   fields[0],
+			Destination: net.IPNet{IP: destination, Mask: net.IPMask(mask)},
+			Gateway:     gateway,
+			Metric:      uint32(metric),
+			Flags:       uint32(flags),
+		}
+		if route.Flags&routeFlagUp != 0 && route.Flags&routeFlagReject == 0 {
+			result = append(result, route)
+		}
+	}
+	return result, scanner.Err()
+}
+
+func parseIpv4RouteAddress(text string) (net.IP, error) {
+	value, err := strconv.ParseUint(text, 16, 32)
+	if err != nil {
+		return nil, err
+	}
+	ip := make(net.IP, net.IPv4len)
+	nativeEndian.PutUint32(ip, uint32(value))
+	return ip, nil
+}
+
+// parseIpv6Routes parses the format of /proc/net/ipv6_route. Addresses are hex encoded in network byte order.
+//
+//	destination prefix source prefix next-hop metric refcnt use flags iface
+//	00000000000000000000000000000000 00 00000000000000000000000000000000 00 fd000000000000000000000000000001 00000400 00000001 00000000 00000003 eth0
+func parseIpv6Routes(reader io.Reader) ([]Route, error) {
+	result := make([]Route, 0)
+	scanner := bufio.NewScanner(reader)
+	for line := 0; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 10 {
+			return nil, fmt.Errorf("invalid route in line %d: %s", line+1, scanner.Text())
+		}
+		destination, err1 := hex.DecodeString(fields[0])
+		prefixLen, err2 := strconv.ParseUint(fields[1], 16, 8)
+		gateway, err3 := hex.DecodeString(fields[4])
+		metric, err4 := strconv.ParseUint(fields[5], 16, 32)
+		flags, err5 := strconv.ParseUint(fields[8], 16, 32)
+		if err := firstError(err1, err2, err3, err4, err5); err != nil {
+			return nil, fmt.Errorf("invalid route in line %d: %v", line+1, err)
+		}
+		if len(destination) != net.IPv6len || len(gateway) != net.IPv6len || prefixLen > 128 {
+			return nil, fmt.Errorf("invalid route in line %d: %s", line+1, scanner.Text())
+		}
+		route := Route{
+			Interface:   fields[9],
+			Destination: net.IPNet{IP: net.IP(destination), Mask: net.CIDRMask(int(prefixLen), 128)},
+			Gateway:     net.IP(gateway),
+			Metric:      uint32(metric),
+			Flags:       uint32(flags),
+		}
+		if route.Flags&routeFlagUp != 0 && route.Flags&routeFlagReject == 0 {
+			result = append(result, route)
+		}
+	}
+	return result, scanner.Err()
+}
+
+// defaultRoute returns the default route with the lowest metric for the given IP version (ipv4 or ipv6).
+//
+//	{{ (defaultRoute "ipv4").Interface }}
+func defaultRoute(ipVersion string) (*Route, error) {
+	table, err := routes(ipVersion)
+	if err != nil {
+		return nil, err
+	}
+	var found *Route
+	for i, route := range table {
+		if route.IsDefault() && (found == nil || route.Metric < found.Metric) {
+			found = &table[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no default route for %s", ipVersion)
+	}
+	return found, nil
+}
+
+// routeTo returns the route which is used to reach the destination. The destination can be
+// an IP address or a hostname. The most specific route with the lowest metric wins.
+//
+//	{{ routeTo "10.0.0.1" }}
+func routeTo(destination string) (*Route, error) {
+	ip, err := resolveDestination(destination)
+	if err != nil {
+		return nil, err
+	}
+	table, err := routes(ipAddrVersion(ip))
+	if err != nil {
+		return nil, err
+	}
+	return lookupRoute(table, ip)
+}
+
+func lookupRoute(table []Route, ip net.IP) (*Route, error) {
+	var found *Route
+	foundLen := -1
+	for i, route := range table {
+		if !route.Destination.Contains(ip) {
+			continue
+		}
+		ones, _ := route.Destination.Mask.Size()
+		if ones > foundLen || (ones == foundLen && route.Metric < found.Metric) {
+			found, foundLen = &table[i], ones
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no route to %s", ip)
+	}
+	return found, nil
+}
+
+// defaultIpAddress returns the address of the interface of the default route for the
+// given IP version (ipv4 or ipv6).
+//
+//	advertised.listeners=PLAINTEXT://{{ defaultIpAddress "ipv4" }}:9092
+func defaultIpAddress(ipVersion string) (*InterfaceAddress, error) {
+	route, err := defaultRoute(ipVersion)
+	if err != nil {
+		return nil, err
+	}
+	return routeSourceAddress(route, route.Gateway)
+}
+
+// sourceIpAddress returns the address of the interface which is used to reach the destination.
+// The destination can be an IP address or a hostname.
+//
+//	advertised.listeners=PLAINTEXT://{{ sourceIpAddress "zookeeper" }}:9092
+func sourceIpAddress(destination string) (*InterfaceAddress, error) {
+	ip, err := resolveDestination(destination)
+	if err != nil {
+		return nil, err
+	}
+	table, err := routes(ipAddrVersion(ip))
+	if err != nil {
+		return nil, err
+	}
+	route, err := lookupRoute(table, ip)
+	if err != nil {
+		return nil, err
+	}
+	if route.HasGateway() {
+		return routeSourceAddress(route, route.Gateway)
+	}
+	return routeSourceAddress(route, ip)
+}
+
+// routeSourceAddress selects an address of the route's interface. An address in the same
+// network as the next hop is preferred.
+func routeSourceAddress(route *Route, nextHop net.IP) (*InterfaceAddress, error) {
+	addresses, err := interfaceAddresses()
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := filterInterfaceAddresses(addresses, func(a InterfaceAddress) bool {
+		return a.Interface == route.Interface && a.Version() == route.Version()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("interface %s of route '%s' has no global unicast %s address", route.Interface, route, route.Version())
+	}
+	for i, candidate := range candidates {
+		network := net.IPNet{IP: candidate.IP, Mask: net.CIDRMask(candidate.PrefixLen, len(candidate.IP)*8)}
+		if nextHop != nil && network.Contains(nextHop) {
+			return &candidates[i], nil
+		}
+	}
+	return &candidates[0], nil
+}
+
+func resolveDestination(destination string) (net.IP, error) {
+	if ip := net.ParseIP(destination); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4, nil
+		}
+		return ip, nil
+	}
+	ips, err := net.LookupIP(destination)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("%s could not be resolved", destination)
+	}
+	if ip4 := ips[0].To4(); ip4 != nil {
+		return ip4, nil
+	}
+	return ips[0], nil
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package template
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ipv4RouteHex encodes an IPv4 address like /proc/net/route, in native byte order.
+func ipv4RouteHex(ip string) string {
+	return fmt.Sprintf("%08X", nativeEndian.Uint32(net.ParseIP(ip).To4()))
+}
+
+func ipv4RouteFixture() string {
+	lines := []string{
+		"Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT",
+		fmt.Sprintf("eth0\t%s\t%s\t0003\t0\t0\t100\t%s\t0\t0\t0", ipv4RouteHex("0.0.0.0"), ipv4RouteHex("172.20.0.1"), ipv4RouteHex("0.0.0.0")),
+		fmt.Sprintf("eth1\t%s\t%s\t0003\t0\t0\t50\t%s\t0\t0\t0", ipv4RouteHex("0.0.0.0"), ipv4RouteHex("10.0.0.1"), ipv4RouteHex("0.0.0.0")),
+		fmt.Sprintf("eth0\t%s\t%s\t0001\t0\t0\t0\t%s\t0\t0\t0", ipv4RouteHex("172.20.0.0"), ipv4RouteHex("0.0.0.0"), ipv4RouteHex("255.255.0.0")),
+		fmt.Sprintf("eth1\t%s\t%s\t0001\t0\t0\t0\t%s\t0\t0\t0", ipv4RouteHex("10.0.0.0"), ipv4RouteHex("0.0.0.0"), ipv4RouteHex("255.0.0.0")),
+		fmt.Sprintf("eth2\t%s\t%s\t0000\t0\t0\t0\t%s\t0\t0\t0", ipv4RouteHex("192.168.0.0"), ipv4RouteHex("0.0.0.0"), ipv4RouteHex("255.255.255.0")),
+		fmt.Sprintf("eth2\t%s\t%s\t0201\t0\t0\t0\t%s\t0\t0\t0", ipv4RouteHex("192.168.1.0"), ipv4RouteHex("0.0.0.0"), ipv4RouteHex("255.255.255.0")),
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+const ipv6RouteFixture = `fd000000000000000000000000000000 40 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 fd000000000000000000000000000001 00000400 00000001 00000000 00000003     eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo
+`
+
+// useRouteFixtures replaces the route table files of the kernel for the duration of a test.
+func useRouteFixtures(t *testing.T, ipv4Routes string, ipv6Routes string) {
+	t.Helper()
+	dir := t.TempDir()
+	ipv4File, ipv6File := filepath.Join(dir, "route"), filepath.Join(dir, "ipv6_route")
+	if err := os.WriteFile(ipv4File, []byte(ipv4Routes), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ipv6File, []byte(ipv6Routes), 0644); err != nil {
+		t.Fatal(err)
+	}
+	previousIpv4, previousIpv6 := procNetRoute, procNetIpv6Route
+	procNetRoute, procNetIpv6Route = ipv4File, ipv6File
+	t.Cleanup(func() { procNetRoute, procNetIpv6Route = previousIpv4, previousIpv6 })
+}
+
+func TestParseIpv4Routes(t *testing.T) {
+	table, err := parseIpv4Routes(strings.NewReader(ipv4RouteFixture()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"default via 172.20.0.1 dev eth0",
+		"default via 10.0.0.1 dev eth1",
+		"172.20.0.0/16 dev eth0",
+		"10.0.0.0/8 dev eth1",
+	}
+	if len(table) != len(expected) {
+		t.Fatalf("expected %d routes, but got %d: %v", len(expected), len(table), table)
+	}
+	for i, route := range table {
+		if route.String() != expected[i] {
+			t.Errorf("route %d: expected %q, but got %q", i, expected[i], route.String())
+		}
+	}
+	if table[0].Metric != 100 || !table[0].IsDefault() || !table[0].HasGateway() || table[0].Version() != ipv4 {
+		t.Errorf("unexpected default route: %+v", table[0])
+	}
+	if table[2].IsDefault() || table[2].HasGateway() {
+		t.Errorf("unexpected network route: %+v", table[2])
+	}
+}
+
+func TestParseIpv6Routes(t *testing.T) {
+	table, err := parseIpv6Routes(strings.NewReader(ipv6RouteFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"fd00::/64 dev eth0",
+		"default via fd00::1 dev eth0",
+	}
+	if len(table) != len(expected) {
+		t.Fatalf("expected %d routes, but got %d: %v", len(expected), len(table), table)
+	}
+	for i, route := range table {
+		if route.String() != expected[i] {
+			t.Errorf("route %d: expected %q, but got %q", i, expected[i], route.String())
+		}
+	}
+	if table[1].Metric != 0x400 || table[1].Version() != ipv6 {
+		t.Errorf("unexpected default route: %+v", table[1])
+	}
+}
+
+func TestParseRoutesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(string) error
+		input string
+	}{
+		{"ipv4 missing fields", func(s string) error { _, err := parseIpv4Routes(strings.NewReader(s)); return err }, "Iface\tDestination\neth0\t00000000\t00000000\n"},
+		{"ipv4 invalid address", func(s string) error { _, err := parseIpv4Routes(strings.NewReader(s)); return err }, "Iface\neth0\tXYZ\t00000000\t0003\t0\t0\t0\t00000000\n"},
+		{"ipv6 missing fields", func(s string) error { _, err := parseIpv6Routes(strings.NewReader(s)); return err }, "00000000000000000000000000000000 00 eth0\n"},
+		{"ipv6 short address", func(s string) error { _, err := parseIpv6Routes(strings.NewReader(s)); return err }, "0000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 00000400 00000001 00000000 00000003 eth0\n"},
+		{"ipv6 invalid prefix", func(s string) error { _, err := parseIpv6Routes(strings.NewReader(s)); return err }, "00000000000000000000000000000000 81 00000000000000000000000000000000 00 00000000000000000000000000000000 00000400 00000001 00000000 00000003 eth0\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.parse(test.input); err == nil {
+				t.Errorf("expected an error for %q", test.input)
+			}
+		})
+	}
+}
+
+func TestDefaultRoute(t *testing.T) {
+	useRouteFixtures(t, ipv4RouteFixture(), ipv6RouteFixture)
+	tests := []struct {
+		ipVersion string
+		expected  string
+	}{
+		{ipv4, "default via 10.0.0.1 dev eth1"},
+		{ipv6, "default via fd00::1 dev eth0"},
+	}
+	for _, test := range tests {
+		route, err := defaultRoute(test.ipVersion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if route.String() != test.expected {
+			t.Errorf("%s: expected %q, but got %q", test.ipVersion, test.expected, route.String())
+		}
+	}
+	if _, err := defaultRoute("ipv5"); err == nil {
+		t.Error("expected an error for an unknown IP version")
+	}
+}
+
+func TestDefaultRouteMissing(t *testing.T) {
+	useRouteFixtures(t, strings.SplitN(ipv4RouteFixture(), "\n", 2)[0]+"\n", "")
+	if _, err := defaultRoute(ipv4); err == nil || err.Error() != "no default route for ipv4" {
+		t.Errorf("expected no default route, but got: %v", err)
+	}
+}
+
+func TestRouteTo(t *testing.T) {
+	useRouteFixtures(t, ipv4RouteFixture(), ipv6RouteFixture)
+	tests := []struct {
+		destination string
+		expected    string
+	}{
+		{"172.20.5.5", "172.20.0.0/16 dev eth0"},
+		{"10.1.1.1", "10.0.0.0/8 dev eth1"},
+		{"8.8.8.8", "default via 10.0.0.1 dev eth1"},
+		{"192.168.1.1", "default via 10.0.0.1 dev eth1"},
+		{"fd00::5", "fd00::/64 dev eth0"},
+		{"2001:db8::1", "default via fd00::1 dev eth0"},
+	}
+	for _, test := range tests {
+		route, err := routeTo(test.destination)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if route.String() != test.expected {
+			t.Errorf("%s: expected %q, but got %q", test.destination, test.expected, route.String())
+		}
+	}
+}
@@ -158,6 +158,27 @@ func noopSink(input <-chan *Data) <-chan *Data  {
 	return out
 }
 
+// holdBackOnError waits until all data is available. If any data has an error, only data with errors
+// is passed on, so that nothing is written if one of the inputs failed.
+func holdBackOnError(input <-chan *Data) <-chan *Data {
+	out := make(chan *Data)
+	go func() {
+		defer close(out)
+		all := make([]*Data, 0)
+		failed := false
+		for data := range input {
+			all = append(all, data)
+			failed = failed || data.Error != nil
+		}
+		for _, data := range all {
+			if !failed || data.Error != nil {
+				out <- data
+			}
+		}
+	}()
+	return out
+}
+
 func waitUntilDone(channel <-chan *Data) (err error) {
 	errors := make([]string, 0)
 	for data := range channel {
@@ -0,0 +1,387 @@
+package template
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Certificate contains the information of a X.509 certificate, which is commonly required for TLS config.
+type Certificate struct {
+	Subject         string
+	Issuer          string
+	CommonName      string
+	DNSNames        []string
+	IPAddresses     []string
+	EmailAddresses  []string
+	URIs            []string
+	SerialNumber    string
+	NotBefore       time.Time
+	NotAfter        time.Time
+	IsCA            bool
+	Fingerprint     string
+	FingerprintSHA1 string
+	PEM             string
+}
+
+func (c Certificate) String() string {
+	return c.Subject
+}
+
+// NewCertificate extracts the information of a parsed certificate. Fingerprints are upper case hex
+// with colons, like keytool and openssl print them.
+func NewCertificate(cert *x509.Certificate) Certificate {
+	ipAddresses := make([]string, 0, len(cert.IPAddresses))
+	for _, ip := range cert.IPAddresses {
+		ipAddresses = append(ipAddresses, ip.String())
+	}
+	uris := make([]string, 0, len(cert.URIs))
+	for _, uri := range cert.URIs {
+		uris = append(uris, uri.String())
+	}
+	sha256Sum := sha256.Sum256(cert.Raw)
+	sha1Sum := sha1.Sum(cert.Raw)
+	return Certificate{
+		Subject:         cert.Subject.String(),
+		Issuer:          cert.Issuer.String(),
+		CommonName:      cert.Subject.CommonName,
+		DNSNames:        append([]string{}, cert.DNSNames...),
+		IPAddresses:     ipAddresses,
+		EmailAddresses:  append([]string{}, cert.EmailAddresses...),
+		URIs:            uris,
+		SerialNumber:    cert.SerialNumber.String(),
+		NotBefore:       cert.NotBefore,
+		NotAfter:        cert.NotAfter,
+		IsCA:            cert.IsCA,
+		Fingerprint:     hexFingerprint(sha256Sum[:]),
+		FingerprintSHA1: hexFingerprint(sha1Sum[:]),
+		PEM:             string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})),
+	}
+}
+
+func hexFingerprint(sum []byte) string {
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// ParseCertificates parses all certificates of PEM encoded data. Other PEM blocks, like keys, are skipped.
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	certs := make([]*x509.Certificate, 0)
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("contains no PEM encoded certificate")
+	}
+	return certs, nil
+}
+
+// ParsePrivateKey parses a PEM encoded private key in PKCS #8, PKCS #1 (RSA) or SEC 1 (EC) format.
+// Encrypted keys are not supported.
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, fmt.Errorf("contains no PEM encoded private key")
+		}
+		var key interface{}
+		var err error
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		case "ENCRYPTED PRIVATE KEY":
+			return nil, fmt.Errorf("encrypted private keys are not supported")
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not parse private key: %v", err)
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("private key of type %T is not supported", key)
+		}
+		return signer, nil
+	}
+}
+
+// parseCert returns the first certificate of a PEM encoded text.
+//
+//	{{ $cert := .Files.Get "tls.crt" | parseCert }}
+//	# {{ $cert.Subject }} valid until {{ $cert.NotAfter.Format "2006-01-02" }}
+//	ssl.truststore.fingerprint={{ $cert.Fingerprint }}
+func parseCert(text string) (Certificate, error) {
+	certs, err := ParseCertificates([]byte(text))
+	if err != nil {
+		return Certificate{}, err
+	}
+	return NewCertificate(certs[0]), nil
+}
+
+// parseCerts returns all certificates of a PEM encoded text, e.g. of a chain.
+func parseCerts(text string) ([]Certificate, error) {
+	certs, err := ParseCertificates([]byte(text))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Certificate, 0, len(certs))
+	for _, cert := range certs {
+		result = append(result, NewCertificate(cert))
+	}
+	return result, nil
+}
+
+// certMatchesKey checks if the public key of the first certificate belongs to the private key.
+//
+//	{{ if not (certMatchesKey (.Files.Get "tls.crt") (.Files.Get "tls.key")) }}{{ fail "key does not match" }}{{ end }}
+func certMatchesKey(certText string, keyText string) (bool, error) {
+	certs, err := ParseCertificates([]byte(certText))
+	if err != nil {
+		return false, err
+	}
+	key, err := ParsePrivateKey([]byte(keyText))
+	if err != nil {
+		return false, err
+	}
+	return KeyMatchesCertificate(key, certs[0]), nil
+}
+
+// KeyMatchesCertificate checks if the public key of the certificate belongs to the private key.
+func KeyMatchesCertificate(key crypto.Signer, cert *x509.Certificate) bool {
+	publicKey, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	return ok && publicKey.Equal(cert.PublicKey)
+}
+
+// pemBundle concatenates PEM encoded texts (or lists of texts) into a bundle, e.g. a certificate
+// with its chain or a trust store. Every argument must contain PEM blocks. Duplicate blocks are
+// removed, and the order is kept.
+//
+//	{{ pemBundle (.Files.Get "tls.crt") (.Files.Get "intermediate.crt") (.Files.Get "ca.crt") }}
+func pemBundle(texts ...interface{}) (string, error) {
+	var bundle bytes.Buffer
+	seen := make(map[string]bool)
+	for _, text := range toFlatListOfStrings(texts) {
+		found := false
+		for rest := []byte(text); ; {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			found = true
+			encoded := pem.EncodeToMemory(block)
+			if !seen[string(encoded)] {
+				seen[string(encoded)] = true
+				bundle.Write(encoded)
+			}
+		}
+		if !found && strings.TrimSpace(text) != "" {
+			return "", fmt.Errorf("contains no PEM block: %.40q", text)
+		}
+	}
+	return bundle.String(), nil
+}
+
+// GeneratedCert is a certificate with its private key, both PEM encoded. The key is PKCS #8 encoded,
+// which is also supported by Java and Kafka.
+type GeneratedCert struct {
+	Cert string
+	Key  string
+}
+
+// certOptions are the options of genCert.
+type certOptions struct {
+	cn           string
+	organization []string
+	dnsNames     []string
+	ipAddresses  []net.IP
+	days         int
+	isCA         bool
+	keyType      string
+	keySize      int
+	signer       *GeneratedCert
+}
+
+func parseCertOptions(options interface{}) (certOptions, error) {
+	parsed := certOptions{days: 365, keyType: "rsa", keySize: 2048}
+	optionsVal := reflect.ValueOf(options)
+	if optionsVal.Kind() != reflect.Map {
+		return parsed, fmt.Errorf("options must be a dict but was %T", options)
+	}
+	iter := optionsVal.MapRange()
+	for iter.Next() {
+		key := strval(iter.Key().Interface())
+		value := iter.Value().Interface()
+		switch key {
+		case "cn":
+			parsed.cn = strval(value)
+		case "o":
+			parsed.organization = toFlatListOfStrings(value)
+		case "dns":
+			parsed.dnsNames = toFlatListOfStrings(value)
+		case "ips":
+			for _, text := range toFlatListOfStrings(value) {
+				ip := net.ParseIP(text)
+				if ip == nil {
+					return parsed, fmt.Errorf("ips must contain ip addresses, but contained: %s", text)
+				}
+				parsed.ipAddresses = append(parsed.ipAddresses, ip)
+			}
+		case "days", "keySize":
+			number, err := strconv.Atoi(strval(value))
+			if err != nil || number <= 0 {
+				return parsed, fmt.Errorf("%s must be a positive number, but was: %v", key, value)
+			}
+			if key == "days" {
+				parsed.days = number
+			} else {
+				parsed.keySize = number
+			}
+		case "ca":
+			isCA, err := strconv.ParseBool(strval(value))
+			if err != nil {
+				return parsed, fmt.Errorf("ca must be a bool, but was: %v", value)
+			}
+			parsed.isCA = isCA
+		case "keyType":
+			parsed.keyType = strval(value)
+			if !contains([]string{"rsa", "ecdsa", "ed25519"}, parsed.keyType) {
+				return parsed, fmt.Errorf("keyType must be one of [rsa, ecdsa, ed25519], but was: %s", parsed.keyType)
+			}
+		case "signer":
+			signer, err := toGeneratedCert(value)
+			if err != nil {
+				return parsed, err
+			}
+			parsed.signer = signer
+		default:
+			return parsed, fmt.Errorf("unknown option %s, supported are [cn, o, dns, ips, days, ca, keyType, keySize, signer]", key)
+		}
+	}
+	return parsed, nil
+}
+
+// toGeneratedCert accepts a GeneratedCert, the certificate of Sprig's genCA or a dict with Cert and Key.
+func toGeneratedCert(value interface{}) (*GeneratedCert, error) {
+	if cert, ok := value.(GeneratedCert); ok {
+		return &cert, nil
+	}
+	val := reflect.Indirect(reflect.ValueOf(value))
+	switch val.Kind() {
+	case reflect.Struct:
+		cert, key := val.FieldByName("Cert"), val.FieldByName("Key")
+		if cert.IsValid() && key.IsValid() {
+			return &GeneratedCert{Cert: strval(cert.Interface()), Key: strval(key.Interface())}, nil
+		}
+	case reflect.Map:
+		m, _ := asStringMap(val.Interface())
+		return &GeneratedCert{Cert: strval(m["Cert"]), Key: strval(m["Key"])}, nil
+	}
+	return nil, fmt.Errorf("signer must have a Cert and a Key, but was %T", value)
+}
+
+// genCert generates a certificate with a new private key, e.g. for dev containers. It is self-signed,
+// or signed by the option signer (e.g. the result of genCert with ca or of Sprig's genCA).
+//
+//	{{ $ca := genCert (dict "cn" "dev-ca" "ca" true) }}
+//	{{ $broker := genCert (dict "cn" "broker" "dns" (list "broker" "localhost") "ips" (list "127.0.0.1") "signer" $ca) }}
+//
+// Supported options are cn, o (organization), dns, ips, days (default 365), ca, keyType (rsa (default),
+// ecdsa or ed25519), keySize (RSA bits, default 2048) and signer.
+func genCert(options interface{}) (GeneratedCert, error) {
+	opts, err := parseCertOptions(options)
+	if err != nil {
+		return GeneratedCert{}, err
+	}
+	var key crypto.Signer
+	switch opts.keyType {
+	case "ecdsa":
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ed25519":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		key, err = rsa.GenerateKey(rand.Reader, opts.keySize)
+	}
+	if err != nil {
+		return GeneratedCert{}, fmt.Errorf("could not generate key: %v", err)
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return GeneratedCert{}, fmt.Errorf("could not generate serial number: %v", err)
+	}
+	now := time.Now()
+	certTemplate := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: opts.cn, Organization: opts.organization},
+		DNSNames:              opts.dnsNames,
+		IPAddresses:           opts.ipAddresses,
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(time.Duration(opts.days) * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  opts.isCA,
+	}
+	if opts.isCA {
+		certTemplate.KeyUsage |= x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	}
+	parent, signerKey := certTemplate, key
+	if opts.signer != nil {
+		signerCerts, err := ParseCertificates([]byte(opts.signer.Cert))
+		if err != nil {
+			return GeneratedCert{}, fmt.Errorf("signer %v", err)
+		}
+		if signerKey, err = ParsePrivateKey([]byte(opts.signer.Key)); err != nil {
+			return GeneratedCert{}, fmt.Errorf("signer %v", err)
+		}
+		parent = signerCerts[0]
+	}
+	der, err := x509.CreateCertificate(rand.Reader, certTemplate, parent, key.Public(), signerKey)
+	if err != nil {
+		return GeneratedCert{}, fmt.Errorf("could not create certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return GeneratedCert{}, fmt.Errorf("could not encode key: %v", err)
+	}
+	return GeneratedCert{
+		Cert: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		Key:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})),
+	}, nil
+}
@@ -0,0 +1,199 @@
+package template
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// validationFuncNames are the functions whose errors are collected instead of aborting
+// the rendering, if Config.CollectErrors is set.
+var validationFuncNames = []string{"required", "requiredEnv", "mustMatch", "oneOf", "inRange", "validURL"}
+
+// required returns the value if it is present, otherwise an error with the given message.
+// Nil values (including nil pointers, maps, slices and interfaces) and empty strings are not present.
+//
+//	{{ .Values.cluster.id | required "cluster.id is required" }}
+func required(warn string, val interface{}) (interface{}, error) {
+	if isNil(val) {
+		return nil, fmt.Errorf(warn)
+	}
+	if s, ok := val.(string); ok && (s == "" || s == "<nil>") {
+		return nil, fmt.Errorf(warn)
+	}
+	return val, nil
+}
+
+func isNil(val interface{}) bool {
+	if val == nil {
+		return true
+	}
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+// requiredEnv returns the value of an environment variable, or an error if it is not set or empty.
+//
+//	zookeeper.connect={{ requiredEnv "KAFKA_ZOOKEEPER_CONNECT" }}
+func requiredEnv(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return "", fmt.Errorf("environment variable %s is required", name)
+	}
+	return value, nil
+}
+
+// mustMatch returns the value if it matches the regular expression completely, otherwise an error.
+//
+//	{{ env "NODE_ID" | mustMatch "[0-9]+" }}
+func mustMatch(pattern string, val interface{}) (interface{}, error) {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %s: %v", pattern, err)
+	}
+	if !re.MatchString(strval(val)) {
+		return nil, fmt.Errorf("value '%s' must match pattern %s", strval(val), pattern)
+	}
+	return val, nil
+}
+
+// oneOf returns the value if it is one of the allowed values, otherwise an error.
+//
+//	{{ env "SECURITY_PROTOCOL" | oneOf (list "PLAINTEXT" "SSL") }}
+func oneOf(allowed interface{}, val interface{}) (interface{}, error) {
+	allowedValues := toFlatListOfStrings(allowed)
+	if !contains(allowedValues, strval(val)) {
+		return nil, fmt.Errorf("value '%s' must be one of %v", strval(val), allowedValues)
+	}
+	return val, nil
+}
+
+// inRange returns the value if it is a number between min and max (both inclusive), otherwise an error.
+//
+//	{{ env "REPLICATION_FACTOR" | inRange 1 3 }}
+func inRange(min interface{}, max interface{}, val interface{}) (interface{}, error) {
+	minNum, err := toNumber(min)
+	if err != nil {
+		return nil, fmt.Errorf("min must be a number, but was: %s", strval(min))
+	}
+	maxNum, err := toNumber(max)
+	if err != nil {
+		return nil, fmt.Errorf("max must be a number, but was: %s", strval(max))
+	}
+	num, err := toNumber(val)
+	if err != nil {
+		return nil, fmt.Errorf("value '%s' must be a number", strval(val))
+	}
+	if num < minNum || num > maxNum {
+		return nil, fmt.Errorf("value '%s' must be in range [%s, %s]", strval(val), strval(min), strval(max))
+	}
+	return val, nil
+}
+
+func toNumber(val interface{}) (float64, error) {
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	default:
+		return strconv.ParseFloat(strings.TrimSpace(strval(val)), 64)
+	}
+}
+
+// validURL returns the value if it is an absolute URL with host, otherwise an error.
+// Optionally, the allowed schemes can be given before the value.
+//
+//	{{ env "SCHEMA_REGISTRY_URL" | validURL "http" "https" }}
+func validURL(args ...interface{}) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("validURL requires a value")
+	}
+	val := args[len(args)-1]
+	schemes := toFlatListOfStrings(args[:len(args)-1])
+	u, err := url.Parse(strval(val))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("value '%s' must be an absolute URL with host", strval(val))
+	}
+	if len(schemes) > 0 && !contains(schemes, u.Scheme) {
+		return nil, fmt.Errorf("value '%s' must have one of the schemes %v", strval(val), schemes)
+	}
+	return val, nil
+}
+
+// validationCollector collects the errors of validation functions during the rendering of a template.
+type validationCollector struct {
+	mutex  sync.Mutex
+	errors []string
+}
+
+func (c *validationCollector) add(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.errors = append(c.errors, err.Error())
+}
+
+func (c *validationCollector) err() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.errors) == 0 {
+		return nil
+	}
+	return fmt.Errorf("validation failed with %d errors:\n\t\t%s", len(c.errors), strings.Join(c.errors, "\n\t\t"))
+}
+
+// collectingFuncs wraps the validation functions, so that errors are added to the collector
+// instead of being returned. Functions which return the validated value return it unchanged
+// (the last argument), so that the rendering can continue.
+func collectingFuncs(funcs map[string]interface{}, collector *validationCollector) map[string]interface{} {
+	wrapped := make(map[string]interface{})
+	for _, name := range validationFuncNames {
+		fn := reflect.ValueOf(funcs[name])
+		fnType := fn.Type()
+		wrapped[name] = reflect.MakeFunc(fnType, func(args []reflect.Value) []reflect.Value {
+			var results []reflect.Value
+			if fnType.IsVariadic() {
+				results = fn.CallSlice(args)
+			} else {
+				results = fn.Call(args)
+			}
+			if err, _ := results[1].Interface().(error); err != nil {
+				collector.add(err)
+				results[0] = reflect.Zero(fnType.Out(0))
+				if last := lastArg(args, fnType.IsVariadic()); last.IsValid() && fnType.Out(0).Kind() == reflect.Interface {
+					results[0] = last
+				}
+				results[1] = reflect.Zero(fnType.Out(1))
+			}
+			return results
+		}).Interface()
+	}
+	return wrapped
+}
+
+func lastArg(args []reflect.Value, variadic bool) reflect.Value {
+	if len(args) == 0 {
+		return reflect.Value{}
+	}
+	last := args[len(args)-1]
+	if variadic {
+		if last.Len() == 0 {
+			return reflect.Value{}
+		}
+		return last.Index(last.Len() - 1)
+	}
+	return last
+}